@@ -0,0 +1,164 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+//go:embed confusables.txt
+var confusablesData string
+
+// confusable is one entry of the confusables table
+type confusable struct {
+	target string // what the rune is mistaken for, e.g. "i"
+	name   string // the rune's Unicode name
+}
+
+var (
+	confusablesOnce sync.Once
+	confusables     map[rune]confusable
+)
+
+// loadConfusables parses the embedded table the first time it is needed.
+// Lines look like "0456 ;\t0069 ;\tMA\t# ( і → i ) CYRILLIC SMALL ... → ...".
+func loadConfusables() map[rune]confusable {
+	confusablesOnce.Do(func() {
+		confusables = make(map[rune]confusable)
+		for _, line := range strings.Split(confusablesData, "\n") {
+			data, comment, _ := strings.Cut(line, "#")
+			fields := strings.Split(data, ";")
+			if len(fields) < 2 {
+				continue
+			}
+			source, err := strconv.ParseUint(strings.TrimSpace(fields[0]), 16, 32)
+			if err != nil {
+				continue
+			}
+			var target strings.Builder
+			for _, hex := range strings.Fields(fields[1]) {
+				if r, err := strconv.ParseUint(hex, 16, 32); err == nil {
+					target.WriteRune(rune(r))
+				}
+			}
+			// The name is between the closing parenthesis and the arrow
+			name := comment
+			if _, after, ok := strings.Cut(comment, ") "); ok {
+				name, _, _ = strings.Cut(after, " →")
+			}
+			confusables[rune(source)] = confusable{target.String(), strings.TrimSpace(name)}
+		}
+	})
+	return confusables
+}
+
+// Skeleton replaces every confusable rune by what it is mistaken for, so
+// strings that look alike get the same skeleton: Skeleton("іan") is "ian".
+// This follows UTS #39 except that the NFD steps are left out.
+func Skeleton(s string) string {
+	table := loadConfusables()
+	var b strings.Builder
+	for _, r := range s {
+		if c, ok := table[r]; ok {
+			b.WriteString(c.target)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// scriptOf returns the name of the script r is written in, or "" for runes
+// such as digits and punctuation that every script shares
+func scriptOf(r rune) string {
+	if unicode.In(r, unicode.Common, unicode.Inherited) {
+		return ""
+	}
+	for name, table := range unicode.Scripts {
+		if unicode.Is(table, r) {
+			return name
+		}
+	}
+	return ""
+}
+
+// Scripts returns the sorted names of the scripts the letters of s use
+func Scripts(s string) []string {
+	seen := make(map[string]bool)
+	for _, r := range s {
+		if script := scriptOf(r); script != "" {
+			seen[script] = true
+		}
+	}
+	scripts := make([]string, 0, len(seen))
+	for script := range seen {
+		scripts = append(scripts, script)
+	}
+	sort.Strings(scripts)
+	return scripts
+}
+
+// ConfusableWarnings explains what in s may not be what it looks like:
+// words that mix scripts, and words made only of look-alike characters.
+// Ordinary Greek or Cyrillic words are left alone, as are ASCII look-alikes
+// such as 1 and l, which are typed on purpose.
+func ConfusableWarnings(s string) []string {
+	table := loadConfusables()
+	var warnings []string
+	seen := make(map[rune]bool)
+	for _, word := range strings.Fields(s) {
+		var lookalikes []rune
+		allLookalikes := true
+		for _, r := range word {
+			if _, ok := table[r]; ok && r > unicode.MaxASCII {
+				lookalikes = append(lookalikes, r)
+			} else if unicode.IsLetter(r) {
+				allLookalikes = false
+			}
+		}
+
+		scripts := Scripts(word)
+		switch {
+		case len(scripts) > 1:
+			warnings = append(warnings, fmt.Sprintf("%q mixes %s scripts", word, strings.Join(scripts, " and ")))
+		case len(lookalikes) > 0 && allLookalikes:
+			warnings = append(warnings, fmt.Sprintf("%q can be mistaken for %q", word, Skeleton(word)))
+		default:
+			continue
+		}
+		for _, r := range lookalikes {
+			if !seen[r] {
+				seen[r] = true
+				c := table[r]
+				warnings = append(warnings, fmt.Sprintf("%q (U+%04X %s) looks like %q", r, r, c.name, c.target))
+			}
+		}
+	}
+	return warnings
+}
+
+// skeletonRules rewrites the literal rules in skeleton form so they can be
+// matched against Skeleton of the input; regex rules are kept as they are
+func skeletonRules(rules []Rule, ignoreCase bool) []Rule {
+	out := make([]Rule, len(rules))
+	for i, rule := range rules {
+		if rule.Kind != Regex {
+			rule.Pattern = skeletonKey(rule.Pattern, ignoreCase)
+		}
+		out[i] = rule
+	}
+	return out
+}
+
+// skeletonKey lowercases s first when case is ignored, since the skeleton
+// of "I" is "l" but the skeleton of "i" is "i"
+func skeletonKey(s string, ignoreCase bool) string {
+	if ignoreCase {
+		s = strings.ToLower(s)
+	}
+	return Skeleton(s)
+}
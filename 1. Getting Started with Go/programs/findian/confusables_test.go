@@ -0,0 +1,96 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSkeleton(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"іan", "ian"}, // Cyrillic і
+		{"ian", "ian"},
+		{"раурал", "paypaл"}, // л has no look-alike
+		{"ıĳ", "iij"},        // dotless i and the ij ligature
+		{"Ian", "lan"},       // capital I looks like l
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Skeleton(tt.in); got != tt.want {
+			t.Errorf("Skeleton(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if skeletonKey("Ian", true) != "ian" {
+		t.Errorf("skeletonKey ignoring case = %q, want ian", skeletonKey("Ian", true))
+	}
+}
+
+func TestScripts(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"ian", []string{"Latin"}},
+		{"іan", []string{"Cyrillic", "Latin"}},
+		{"Москва 2024!", []string{"Cyrillic"}},
+		{"Αθήνα", []string{"Greek"}},
+		{"123 - ?", []string{}},
+	}
+	for _, tt := range tests {
+		if got := Scripts(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("Scripts(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestConfusableWarnings(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		// The case from the bug report: a Cyrillic і in a Latin word
+		{"іan", []string{
+			`"іan" mixes Cyrillic and Latin scripts`,
+			`'і' (U+0456 CYRILLIC SMALL LETTER BYELORUSSIAN-UKRAINIAN I) looks like "i"`,
+		}},
+		// Only look-alikes, all of one script
+		{"соре", []string{
+			`"соре" can be mistaken for "cope"`,
+			`'с' (U+0441 CYRILLIC SMALL LETTER ES) looks like "c"`,
+			`'о' (U+043E CYRILLIC SMALL LETTER O) looks like "o"`,
+			`'р' (U+0440 CYRILLIC SMALL LETTER ER) looks like "p"`,
+			`'е' (U+0435 CYRILLIC SMALL LETTER IE) looks like "e"`,
+		}},
+		// Each rune is explained once
+		{"іan іn", []string{
+			`"іan" mixes Cyrillic and Latin scripts`,
+			`'і' (U+0456 CYRILLIC SMALL LETTER BYELORUSSIAN-UKRAINIAN I) looks like "i"`,
+			`"іn" mixes Cyrillic and Latin scripts`,
+		}},
+		// Ordinary words, and ASCII look-alikes typed on purpose
+		{"Москва", nil},
+		{"привет мир", nil},
+		{"Αθήνα", nil},
+		{"ian", nil},
+		{"Il1 |0O", nil},
+	}
+	for _, tt := range tests {
+		if got := ConfusableWarnings(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("ConfusableWarnings(%q) =\n%q\nwant\n%q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSkeletonRules(t *testing.T) {
+	rules := []Rule{{Prefix, "I"}, {Contains, "а"}, {Regex, "I[0-9]"}}
+	want := []Rule{{Prefix, "i"}, {Contains, "a"}, {Regex, "I[0-9]"}}
+	if got := skeletonRules(rules, true); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	d, err := Compile(skeletonRules(defaultRules, true), CompileOptions{IgnoreCase: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.MatchAll(skeletonKey("іan", true)) {
+		t.Error(`"іan" does not match the findian rules in skeleton form`)
+	}
+}
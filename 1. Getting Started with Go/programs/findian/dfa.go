@@ -0,0 +1,510 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxStates stops a pathological regex from building a huge automaton
+const maxStates = 1 << 16
+
+// CompileOptions controls Compile
+type CompileOptions struct {
+	IgnoreCase bool // match upper and lower case alike, as findian always did
+}
+
+// DFA decides every rule of a rule set in a single pass over a string.
+//
+// Runes are grouped into classes that no rule tells apart, so the
+// transition table has one column per class rather than per rune. Each
+// state records which rules hold if the string ends there.
+type DFA struct {
+	Rules []Rule
+
+	bounds     []rune // class i is the runes from bounds[i] up to bounds[i+1]
+	asciiClass [128]int32
+	trans      [][]int32 // state -> class -> state
+	accept     []uint64  // state -> bit i set when rule i matches
+	start      int32
+}
+
+// Compile builds one minimal automaton for all the rules. At most 64 rules
+// fit, and regexes may use the \A, ^, $ and \z anchors but not \b.
+func Compile(rules []Rule, opts CompileOptions) (*DFA, error) {
+	d, err := build(rules, opts)
+	if err != nil {
+		return nil, err
+	}
+	d.minimize()
+	return d, nil
+}
+
+// build makes the product automaton of the rules, before minimization
+func build(rules []Rule, opts CompileOptions) (*DFA, error) {
+	if len(rules) == 0 || len(rules) > 64 {
+		return nil, fmt.Errorf("need between 1 and 64 rules, not %d", len(rules))
+	}
+
+	flags := syntax.Perl
+	if opts.IgnoreCase {
+		flags |= syntax.FoldCase
+	}
+	progs := make([]*syntax.Prog, len(rules))
+	for i, rule := range rules {
+		re, err := syntax.Parse(rule.expr(), flags)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %v", rule, err)
+		}
+		prog, err := syntax.Compile(re.Simplify())
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %v", rule, err)
+		}
+		if err := checkAnchors(prog); err != nil {
+			return nil, fmt.Errorf("rule %q: %v", rule, err)
+		}
+		progs[i] = prog
+	}
+
+	d := &DFA{Rules: rules, bounds: runeClasses(progs)}
+	for r := range d.asciiClass {
+		d.asciiClass[r] = int32(d.searchClass(rune(r)))
+	}
+
+	parts := make([]*ruleDFA, len(progs))
+	for i, prog := range progs {
+		part, err := buildRuleDFA(prog, d.bounds)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %v", rules[i], err)
+		}
+		parts[i] = part
+	}
+	if err := d.product(parts); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// checkAnchors rejects the empty-width tests the automaton can't decide
+// from the current rune alone
+func checkAnchors(prog *syntax.Prog) error {
+	for _, inst := range prog.Inst {
+		if inst.Op != syntax.InstEmptyWidth {
+			continue
+		}
+		if syntax.EmptyOp(inst.Arg)&(syntax.EmptyWordBoundary|syntax.EmptyNoWordBoundary) != 0 {
+			return fmt.Errorf(`\b and \B are not supported`)
+		}
+		if syntax.EmptyOp(inst.Arg)&(syntax.EmptyBeginLine|syntax.EmptyEndLine) != 0 {
+			return fmt.Errorf("multi-line anchors are not supported")
+		}
+	}
+	return nil
+}
+
+// runeClasses splits the runes into ranges that every instruction of every
+// program treats the same way, and returns where each range starts
+func runeClasses(progs []*syntax.Prog) []rune {
+	points := map[rune]bool{0: true}
+	cut := func(lo, hi rune) {
+		points[lo] = true
+		if hi < unicode.MaxRune {
+			points[hi+1] = true
+		}
+	}
+	for _, prog := range progs {
+		for _, inst := range prog.Inst {
+			switch inst.Op {
+			case syntax.InstRune1:
+				cut(inst.Rune[0], inst.Rune[0])
+			case syntax.InstRuneAnyNotNL:
+				cut('\n', '\n')
+			case syntax.InstRune:
+				if len(inst.Rune) == 1 {
+					// A single rune with case folding matches its whole fold orbit
+					r := inst.Rune[0]
+					cut(r, r)
+					for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+						cut(f, f)
+					}
+					continue
+				}
+				for i := 0; i+1 < len(inst.Rune); i += 2 {
+					cut(inst.Rune[i], inst.Rune[i+1])
+				}
+			}
+		}
+	}
+
+	bounds := make([]rune, 0, len(points))
+	for r := range points {
+		bounds = append(bounds, r)
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	return bounds
+}
+
+// searchClass finds the class of r by binary search
+func (d *DFA) searchClass(r rune) int {
+	return sort.Search(len(d.bounds), func(i int) bool { return d.bounds[i] > r }) - 1
+}
+
+// class returns the class of r, with a table lookup for ASCII
+func (d *DFA) class(r rune) int32 {
+	if r >= 0 && r < 128 {
+		return d.asciiClass[r]
+	}
+	return int32(d.searchClass(r))
+}
+
+// ruleDFA is the automaton for a single rule, before the rules are combined
+type ruleDFA struct {
+	trans  [][]int32
+	accept []bool
+}
+
+// nfa runs a regexp program as a set of program counters
+type nfa struct {
+	prog *syntax.Prog
+}
+
+// closure follows every instruction that doesn't consume a rune. It returns
+// the sorted set of rune instructions reached, plus anchors that may still
+// pass once the end of the string is known, and whether Match was reached.
+func (n nfa) closure(pcs []uint32, atStart, atEnd bool) ([]uint32, bool) {
+	seen := make(map[uint32]bool)
+	var set []uint32
+	matched := false
+	stack := append([]uint32(nil), pcs...)
+	for len(stack) > 0 {
+		pc := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[pc] {
+			continue
+		}
+		seen[pc] = true
+
+		inst := &n.prog.Inst[pc]
+		switch inst.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			stack = append(stack, inst.Out, inst.Arg)
+		case syntax.InstCapture, syntax.InstNop:
+			stack = append(stack, inst.Out)
+		case syntax.InstEmptyWidth:
+			op := syntax.EmptyOp(inst.Arg)
+			if op&syntax.EmptyBeginText != 0 && !atStart {
+				continue // can never pass again
+			}
+			if op&syntax.EmptyEndText != 0 && !atEnd {
+				set = append(set, pc) // passes if the string ends here
+				continue
+			}
+			stack = append(stack, inst.Out)
+		case syntax.InstMatch:
+			matched = true
+		case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+			set = append(set, pc)
+		}
+	}
+	sort.Slice(set, func(i, j int) bool { return set[i] < set[j] })
+	return set, matched
+}
+
+// step moves every rune instruction in set over r
+func (n nfa) step(set []uint32, r rune) []uint32 {
+	var next []uint32
+	for _, pc := range set {
+		inst := &n.prog.Inst[pc]
+		ok := false
+		switch inst.Op {
+		case syntax.InstRune:
+			ok = inst.MatchRune(r)
+		case syntax.InstRune1:
+			ok = r == inst.Rune[0]
+		case syntax.InstRuneAny:
+			ok = true
+		case syntax.InstRuneAnyNotNL:
+			ok = r != '\n'
+		}
+		if ok {
+			next = append(next, inst.Out)
+		}
+	}
+	return next
+}
+
+// setKey turns a set of program counters into a map key
+func setKey(set []uint32, atStart bool) string {
+	var b strings.Builder
+	if atStart {
+		b.WriteByte('^')
+	}
+	for _, pc := range set {
+		b.WriteString(strconv.FormatUint(uint64(pc), 36))
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// buildRuleDFA turns one program into an automaton by subset construction.
+// The program is run unanchored, the way regexp.MatchString runs it: the
+// start instruction is added again at every position. State 0 means a match
+// has been seen, which no later rune can undo.
+func buildRuleDFA(prog *syntax.Prog, bounds []rune) (*ruleDFA, error) {
+	n := nfa{prog}
+	d := &ruleDFA{
+		trans:  [][]int32{make([]int32, len(bounds))}, // state 0 loops to itself
+		accept: []bool{true},
+	}
+	ids := map[string]int32{}
+	var sets [][]uint32
+
+	add := func(set []uint32, matched, atStart bool) int32 {
+		if matched {
+			return 0
+		}
+		key := setKey(set, atStart)
+		if id, ok := ids[key]; ok {
+			return id
+		}
+		id := int32(len(d.trans))
+		ids[key] = id
+		d.trans = append(d.trans, nil)
+		_, endMatch := n.closure(set, atStart, true)
+		d.accept = append(d.accept, endMatch)
+		sets = append(sets, set)
+		return id
+	}
+
+	set, matched := n.closure([]uint32{uint32(prog.Start)}, true, false)
+	if add(set, matched, true) == 0 {
+		return d, nil // the rule matches every string
+	}
+	for i := 0; i < len(sets); i++ {
+		if len(d.trans) > maxStates {
+			return nil, fmt.Errorf("automaton has more than %d states", maxStates)
+		}
+		row := make([]int32, len(bounds))
+		for c, r := range bounds {
+			next := append(n.step(sets[i], r), uint32(prog.Start))
+			set, matched := n.closure(next, false, false)
+			row[c] = add(set, matched, false)
+		}
+		d.trans[i+1] = row
+	}
+	return d, nil
+}
+
+// product runs the rule automata side by side; a state of the result is a
+// tuple with one state of each rule
+func (d *DFA) product(parts []*ruleDFA) error {
+	ids := map[string]int32{}
+	var tuples [][]int32
+
+	add := func(tuple []int32) int32 {
+		var b strings.Builder
+		for _, s := range tuple {
+			b.WriteString(strconv.Itoa(int(s)))
+			b.WriteByte(',')
+		}
+		if id, ok := ids[b.String()]; ok {
+			return id
+		}
+		id := int32(len(tuples))
+		ids[b.String()] = id
+		tuples = append(tuples, tuple)
+
+		var mask uint64
+		for i, s := range tuple {
+			if parts[i].accept[s] {
+				mask |= 1 << i
+			}
+		}
+		d.accept = append(d.accept, mask)
+		return id
+	}
+
+	// Every rule automaton starts in its state 1, or 0 if it always matches
+	start := make([]int32, len(parts))
+	for i, p := range parts {
+		if len(p.trans) > 1 {
+			start[i] = 1
+		}
+	}
+	d.start = add(start)
+	for i := 0; i < len(tuples); i++ {
+		if len(tuples) > maxStates {
+			return fmt.Errorf("automaton has more than %d states", maxStates)
+		}
+		row := make([]int32, len(d.bounds))
+		for c := range d.bounds {
+			next := make([]int32, len(parts))
+			for j, p := range parts {
+				next[j] = p.trans[tuples[i][j]][c]
+			}
+			row[c] = add(next)
+		}
+		d.trans = append(d.trans, row)
+	}
+	return nil
+}
+
+// minimize merges states that accept the same rules for every possible
+// rest of the string (Moore's partition refinement)
+func (d *DFA) minimize() {
+	// Start with the states split by the rules they accept
+	block := make([]int32, len(d.trans))
+	byMask := map[uint64]int32{}
+	for s, mask := range d.accept {
+		if _, ok := byMask[mask]; !ok {
+			byMask[mask] = int32(len(byMask))
+		}
+		block[s] = byMask[mask]
+	}
+	count := len(byMask)
+
+	// Split blocks whose states move to different blocks on some class
+	for {
+		next := make([]int32, len(d.trans))
+		ids := map[string]int32{}
+		var b strings.Builder
+		for s, row := range d.trans {
+			b.Reset()
+			b.WriteString(strconv.Itoa(int(block[s])))
+			for _, t := range row {
+				b.WriteByte(',')
+				b.WriteString(strconv.Itoa(int(block[t])))
+			}
+			id, ok := ids[b.String()]
+			if !ok {
+				id = int32(len(ids))
+				ids[b.String()] = id
+			}
+			next[s] = id
+		}
+		block = next
+		if len(ids) == count {
+			break
+		}
+		count = len(ids)
+	}
+
+	trans := make([][]int32, count)
+	accept := make([]uint64, count)
+	for s, row := range d.trans {
+		b := block[s]
+		if trans[b] != nil {
+			continue
+		}
+		trans[b] = make([]int32, len(row))
+		for c, t := range row {
+			trans[b][c] = block[t]
+		}
+		accept[b] = d.accept[s]
+	}
+	d.trans, d.accept, d.start = trans, accept, block[d.start]
+}
+
+// Match returns which rules s satisfies: bit i is set when rule i matches
+func (d *DFA) Match(s string) uint64 {
+	state := d.start
+	for _, r := range s {
+		state = d.trans[state][d.class(r)]
+	}
+	return d.accept[state]
+}
+
+// MatchAll reports whether s satisfies every rule
+func (d *DFA) MatchAll(s string) bool {
+	return d.Match(s) == 1<<len(d.Rules)-1
+}
+
+// MatchAny reports whether s satisfies at least one rule
+func (d *DFA) MatchAny(s string) bool {
+	return d.Match(s) != 0
+}
+
+// States returns the number of states of the automaton
+func (d *DFA) States() int {
+	return len(d.trans)
+}
+
+// Classes returns the number of rune classes
+func (d *DFA) Classes() int {
+	return len(d.bounds)
+}
+
+// runeLabel writes a rune readably for a DOT label
+func runeLabel(r rune) string {
+	q := strconv.QuoteRune(r)
+	return q[1 : len(q)-1]
+}
+
+// WriteDOT writes the automaton in Graphviz DOT format. Double circles are
+// states where a string may end; their labels list the rules that hold.
+// Each state's busiest edge is labelled "other" to keep the graph readable.
+func (d *DFA) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph findian {\n\trankdir=LR;\n\tnode [shape=circle];\n")
+	fmt.Fprintf(&b, "\tstart [shape=point];\n\tstart -> s%d;\n", d.start)
+
+	for s, row := range d.trans {
+		shape, label := "circle", strconv.Itoa(s)
+		if d.accept[s] != 0 {
+			shape = "doublecircle"
+			var matched []string
+			for i := range d.Rules {
+				if d.accept[s]&(1<<i) != 0 {
+					matched = append(matched, strconv.Itoa(i+1))
+				}
+			}
+			label += "\\n{" + strings.Join(matched, ",") + "}"
+		}
+		fmt.Fprintf(&b, "\ts%d [shape=%s label=\"%s\"];\n", s, shape, label)
+
+		// Group the classes by the state they lead to
+		var targets []int32
+		ranges := map[int32][]string{}
+		for c, t := range row {
+			if _, ok := ranges[t]; !ok {
+				targets = append(targets, t)
+			}
+			lo := d.bounds[c]
+			hi := rune(unicode.MaxRune)
+			if c+1 < len(d.bounds) {
+				hi = d.bounds[c+1] - 1
+			}
+			text := runeLabel(lo)
+			if hi > lo {
+				text += "-" + runeLabel(hi)
+			}
+			ranges[t] = append(ranges[t], text)
+		}
+		busiest := targets[0]
+		for _, t := range targets {
+			if len(ranges[t]) > len(ranges[busiest]) {
+				busiest = t
+			}
+		}
+		for _, t := range targets {
+			text := "other"
+			if t != busiest || len(targets) == 1 && len(ranges[t]) == 1 {
+				text = strings.Join(ranges[t], " ")
+			}
+			text = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text)
+			fmt.Fprintf(&b, "\ts%d -> s%d [label=\"%s\"];\n", s, t, text)
+		}
+	}
+
+	b.WriteString("\tlabel=\"")
+	for i, rule := range d.Rules {
+		fmt.Fprintf(&b, "%d: %s\\l", i+1, strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(rule.String()))
+	}
+	b.WriteString("\";\n}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
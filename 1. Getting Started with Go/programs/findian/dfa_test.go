@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// stringsMatcher checks the rules one by one with the strings package, the
+// way findian.go first did; regex rules fall back to regexp
+func stringsMatcher(rules []Rule, ignoreCase bool) func(string) bool {
+	regexps := make([]*regexp.Regexp, len(rules))
+	for i, rule := range rules {
+		if rule.Kind == Regex {
+			regexps[i] = regexp.MustCompile(caseFlag(ignoreCase) + rule.Pattern)
+		}
+	}
+	return func(s string) bool {
+		if ignoreCase {
+			s = strings.ToLower(s)
+		}
+		for i, rule := range rules {
+			p := rule.Pattern
+			if ignoreCase {
+				p = strings.ToLower(p)
+			}
+			var ok bool
+			switch rule.Kind {
+			case Prefix:
+				ok = strings.HasPrefix(s, p)
+			case Suffix:
+				ok = strings.HasSuffix(s, p)
+			case Contains:
+				ok = strings.Contains(s, p)
+			default:
+				ok = regexps[i].MatchString(s)
+			}
+			if !ok {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// regexpMasks compiles each rule with regexp and returns which of them s
+// matches, as Match does
+func regexpMasks(rules []Rule, ignoreCase bool) func(string) uint64 {
+	regexps := make([]*regexp.Regexp, len(rules))
+	for i, rule := range rules {
+		regexps[i] = regexp.MustCompile(caseFlag(ignoreCase) + rule.expr())
+	}
+	return func(s string) uint64 {
+		var mask uint64
+		for i, re := range regexps {
+			if re.MatchString(s) {
+				mask |= 1 << i
+			}
+		}
+		return mask
+	}
+}
+
+func caseFlag(ignoreCase bool) string {
+	if ignoreCase {
+		return "(?i)"
+	}
+	return ""
+}
+
+// testInputs makes n strings from the runes the rules mention, in both
+// cases, plus a few others, so a good share of them match. Every prefix of
+// every pattern is included too.
+func testInputs(rules []Rule, n int) []string {
+	letters := []rune("xyz é.")
+	var inputs []string
+	for _, rule := range rules {
+		letters = append(letters, []rune(rule.Pattern+strings.ToUpper(rule.Pattern))...)
+		for i := range rule.Pattern {
+			inputs = append(inputs, rule.Pattern[:i], rule.Pattern[i:])
+		}
+		inputs = append(inputs, rule.Pattern, strings.ToUpper(rule.Pattern))
+	}
+	rng := rand.New(rand.NewSource(1))
+	for len(inputs) < n {
+		word := make([]rune, rng.Intn(20))
+		for j := range word {
+			word[j] = letters[rng.Intn(len(letters))]
+		}
+		inputs = append(inputs, string(word))
+	}
+	return inputs
+}
+
+// ruleSets are compiled in the differential tests: every kind alone and
+// together
+var ruleSets = [][]Rule{
+	defaultRules,
+	{{Prefix, "ian"}},
+	{{Suffix, "ana"}},
+	{{Contains, "abab"}},
+	{{Contains, "aab"}, {Contains, "aba"}},
+	{{Regex, "a[0-9]+b"}},
+	{{Regex, "^(ab|ba)*$"}},
+	{{Regex, `x.y`}},
+	{{Regex, "(?i)Z"}, {Prefix, "x"}},
+	{{Prefix, "é"}, {Suffix, "É"}},
+	{{Prefix, "a"}, {Suffix, "a"}, {Contains, "bb"}, {Regex, "b{2,3}a"}},
+	{{Prefix, "ab"}, {Prefix, "abc"}, {Suffix, "c"}, {Contains, "ca"}, {Regex, `\Ac*a\z`}},
+}
+
+func TestDFAMatchesRegexp(t *testing.T) {
+	for _, rules := range ruleSets {
+		for _, ignoreCase := range []bool{true, false} {
+			name := fmt.Sprintf("%v/ignore-case=%v", rules, ignoreCase)
+			t.Run(name, func(t *testing.T) {
+				opts := CompileOptions{IgnoreCase: ignoreCase}
+				full, err := build(rules, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				minimal, err := Compile(rules, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if minimal.States() > full.States() {
+					t.Errorf("minimizing went from %d to %d states", full.States(), minimal.States())
+				}
+
+				want := regexpMasks(rules, ignoreCase)
+				all := stringsMatcher(rules, ignoreCase)
+				for _, s := range testInputs(rules, 2000) {
+					mask := want(s)
+					if got := full.Match(s); got != mask {
+						t.Errorf("product automaton: Match(%q) = %b, want %b", s, got, mask)
+					}
+					if got := minimal.Match(s); got != mask {
+						t.Errorf("minimal automaton: Match(%q) = %b, want %b", s, got, mask)
+					}
+					if minimal.MatchAll(s) != all(s) {
+						t.Errorf("MatchAll(%q) = %v, strings says %v", s, !all(s), all(s))
+					}
+					if minimal.MatchAny(s) != (mask != 0) {
+						t.Errorf("MatchAny(%q) = %v, want %v", s, mask == 0, mask != 0)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestMinimizeIsMinimal(t *testing.T) {
+	for _, rules := range ruleSets {
+		d, err := Compile(rules, CompileOptions{IgnoreCase: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		states := d.States()
+		d.minimize()
+		if d.States() != states {
+			t.Errorf("%v: minimizing again went from %d to %d states", rules, states, d.States())
+		}
+	}
+
+	// Starts with "i", contains "a" and ends with "n": the start state, then
+	// whether the first rune was i, an a has been seen and the last rune is n
+	d, err := Compile(defaultRules, CompileOptions{IgnoreCase: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.States() != 1+2*2*2 {
+		t.Errorf("default rules take %d states, want 9", d.States())
+	}
+}
+
+func TestCompileRejects(t *testing.T) {
+	tooMany := make([]Rule, 65)
+	for i := range tooMany {
+		tooMany[i] = Rule{Contains, "a"}
+	}
+	for _, rules := range [][]Rule{
+		nil,
+		tooMany,
+		{{Regex, `\bword`}},
+		{{Regex, "a("}},
+	} {
+		if _, err := Compile(rules, CompileOptions{}); err == nil {
+			t.Errorf("%v: no error", rules)
+		}
+	}
+}
+
+func BenchmarkMatch(b *testing.B) {
+	rules := []Rule{{Prefix, "i"}, {Contains, "an"}, {Suffix, "n"}, {Regex, "[0-9]{2}"}}
+	inputs := testInputs(rules, 1000)
+	d, err := Compile(rules, CompileOptions{IgnoreCase: true})
+	if err != nil {
+		b.Fatal(err)
+	}
+	regexps := make([]*regexp.Regexp, len(rules))
+	for i, rule := range rules {
+		regexps[i] = regexp.MustCompile("(?i)" + rule.expr())
+	}
+	all := func(s string) bool {
+		for _, re := range regexps {
+			if !re.MatchString(s) {
+				return false
+			}
+		}
+		return true
+	}
+	matchers := []struct {
+		name  string
+		match func(string) bool
+	}{
+		{"dfa", d.MatchAll},
+		{"strings", stringsMatcher(rules, true)},
+		{"regexp", all},
+	}
+	for _, m := range matchers {
+		b.Run(m.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				m.match(inputs[i%len(inputs)])
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+	rulesFile := flag.String("rules", "", "file with one rule per line, e.g. \"prefix i\" (default: starts with i, contains a, ends with n)")
+	caseSensitive := flag.Bool("case-sensitive", false, "tell upper and lower case apart")
+	anyRule := flag.Bool("any", false, "report Found when any rule matches instead of all of them")
+	dot := flag.String("dot", "", "write the automaton to this Graphviz DOT file")
+	gen := flag.String("gen", "", "generate test data instead: count, enumerate, sample or near-miss")
+	alphabet := flag.String("alphabet", "abcdefghijklmnopqrstuvwxyz", "runes -gen builds strings from")
+	maxLen := flag.Int("max-len", 6, "longest string -gen builds")
+	n := flag.Int("n", 10, "how many strings -gen prints")
+	seed := flag.Int64("seed", 1, "random seed for -gen sample and near-miss")
+	scope := flag.String("scope", "line", "apply the rules to the whole line, each word or each sentence")
+	tokenize := flag.String("tokenize", "whitespace", "how -scope word finds words: whitespace or unicode")
+	rulesDir := flag.String("rules-dir", "", "serve the rule sets in this directory (one <set>.rules file each), reloading them when they change")
+	httpAddr := flag.String("http", "", "with -rules-dir, serve JSON on this address, e.g. localhost:8081")
+	socketPath := flag.String("socket", "", "with -rules-dir, serve the line protocol on this Unix socket")
+	skeleton := flag.Bool("skeleton", false, "match look-alike characters, e.g. Cyrillic і as i, using Unicode confusables")
+	flag.Parse()
+
+	split, err := splitter(Scope(*scope), *tokenize)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	if *rulesDir != "" {
+		err := runServer(*rulesDir, *httpAddr, *socketPath, CompileOptions{IgnoreCase: !*caseSensitive}, *skeleton)
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	rules := defaultRules
+	if *rulesFile != "" {
+		if rules, err = loadRules(*rulesFile); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+	}
+
+	if *skeleton {
+		rules = skeletonRules(rules, !*caseSensitive)
+	}
+
+	// All the rules are decided in one pass over the string
+	dfa, err := Compile(rules, CompileOptions{IgnoreCase: !*caseSensitive})
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	if *dot != "" {
+		file, err := os.Create(*dot)
+		if err == nil {
+			err = dfa.WriteDOT(file)
+			file.Close()
+		}
+		if err != nil {
+			fmt.Println("Error writing DOT file:", err)
+			os.Exit(1)
+		}
+	}
+
+	match := dfa.MatchAll
+	if *anyRule {
+		match = dfa.MatchAny
+	}
+	if *skeleton {
+		compiled := match
+		match = func(s string) bool {
+			return compiled(skeletonKey(s, !*caseSensitive))
+		}
+	}
+
+	if *gen != "" {
+		accepts := func(mask uint64) bool { return mask == 1<<len(rules)-1 }
+		if *anyRule {
+			accepts = func(mask uint64) bool { return mask != 0 }
+		}
+		runes := uniqueRunes(*alphabet)
+		if len(runes) == 0 || *maxLen < 0 {
+			fmt.Println("Error: -gen needs a non-empty -alphabet and a -max-len of 0 or more")
+			os.Exit(1)
+		}
+		g := NewGenerator(dfa, runes, *maxLen, accepts)
+		if err := runGenerator(os.Stdout, g, *gen, *n, *seed); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Strings given as arguments are checked without prompting
+	if flag.NArg() > 0 {
+		for _, s := range flag.Args() {
+			warnConfusables(s)
+			fmt.Print(s, ": ")
+			report(s, split, match, Scope(*scope) != ScopeLine)
+		}
+		return
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print("Enter a String: ")
+	name, _ := reader.ReadString('\n') // Reads full line including spaces
+	name = strings.TrimSpace(name)     // Removes newline and extra spaces
+	warnConfusables(name)
+	report(name, split, match, Scope(*scope) != ScopeLine)
+}
+
+// report prints Found or Not Found for s, and with listTokens which words
+// or sentences matched and where they start
+func report(s string, split Tokenizer, match func(string) bool, listTokens bool) {
+	matched := MatchTokens(s, split, match)
+	fmt.Println(result(len(matched) > 0))
+	if listTokens {
+		for _, t := range matched {
+			fmt.Printf("  %q at %d\n", t.Text, t.Start)
+		}
+	}
+}
+
+// warnConfusables tells the user, on stderr, when s holds characters that
+// only look like the ones a rule expects
+func warnConfusables(s string) {
+	for _, w := range ConfusableWarnings(s) {
+		fmt.Fprintln(os.Stderr, "Warning:", w)
+	}
+}
+
+// uniqueRunes returns the runes of s in order, without repeats
+func uniqueRunes(s string) []rune {
+	var runes []rune
+	seen := make(map[rune]bool)
+	for _, r := range s {
+		if !seen[r] {
+			seen[r] = true
+			runes = append(runes, r)
+		}
+	}
+	return runes
+}
+
+func result(found bool) string {
+	if found {
+		return "Found"
+	}
+	return "Not Found"
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"math/rand"
+	"strings"
+)
+
+// Generator lists, counts and samples the strings over an alphabet, up to a
+// maximum length, that a rule set accepts. It works on the compiled DFA:
+// ways[k][s] is how many strings of exactly k runes lead from state s to a
+// state that accepts, and every operation is read off that table.
+type Generator struct {
+	dfa      *DFA
+	alphabet []rune
+	maxLen   int
+	accepts  func(mask uint64) bool
+	ways     [][]*big.Int
+}
+
+// NewGenerator prepares a generator; accepts decides from Match's bit mask
+// whether a string counts as matching, e.g. all rules or any rule
+func NewGenerator(d *DFA, alphabet []rune, maxLen int, accepts func(mask uint64) bool) *Generator {
+	g := &Generator{dfa: d, alphabet: alphabet, maxLen: maxLen, accepts: accepts}
+	g.ways = make([][]*big.Int, maxLen+1)
+	for k := range g.ways {
+		g.ways[k] = make([]*big.Int, d.States())
+		for s := range g.ways[k] {
+			n := new(big.Int)
+			switch {
+			case k == 0 && accepts(d.accept[s]):
+				n.SetInt64(1)
+			case k > 0:
+				for _, r := range alphabet {
+					n.Add(n, g.ways[k-1][g.next(int32(s), r)])
+				}
+			}
+			g.ways[k][s] = n
+		}
+	}
+	return g
+}
+
+func (g *Generator) next(s int32, r rune) int32 {
+	return g.dfa.trans[s][g.dfa.class(r)]
+}
+
+// matches runs s through the automaton
+func (g *Generator) matches(s []rune) bool {
+	state := g.dfa.start
+	for _, r := range s {
+		state = g.next(state, r)
+	}
+	return g.accepts(g.dfa.accept[state])
+}
+
+// Count returns the exact number of matching strings of every length up to
+// the maximum, the empty string included
+func (g *Generator) Count() *big.Int {
+	total := new(big.Int)
+	for k := range g.ways {
+		total.Add(total, g.ways[k][g.dfa.start])
+	}
+	return total
+}
+
+// Enumerate calls fn with every matching string, shortest first and in
+// alphabet order within a length, until fn returns false
+func (g *Generator) Enumerate(fn func(string) bool) {
+	word := make([]rune, 0, g.maxLen)
+	var walk func(state int32, left int) bool
+	walk = func(state int32, left int) bool {
+		if left == 0 {
+			return fn(string(word))
+		}
+		for _, r := range g.alphabet {
+			next := g.next(state, r)
+			if g.ways[left-1][next].Sign() == 0 {
+				continue // nothing down this way matches
+			}
+			word = append(word, r)
+			ok := walk(next, left-1)
+			word = word[:len(word)-1]
+			if !ok {
+				return false
+			}
+		}
+		return true
+	}
+	for k := 0; k <= g.maxLen; k++ {
+		if g.ways[k][g.dfa.start].Sign() > 0 && !walk(g.dfa.start, k) {
+			return
+		}
+	}
+}
+
+// Sample picks one matching string uniformly at random from all of them.
+// It returns false when no string matches.
+func (g *Generator) Sample(rng *rand.Rand) (string, bool) {
+	total := g.Count()
+	if total.Sign() == 0 {
+		return "", false
+	}
+	// Pick the i-th matching string, then find it by walking the table
+	i := new(big.Int).Rand(rng, total)
+	k := 0
+	for ; i.Cmp(g.ways[k][g.dfa.start]) >= 0; k++ {
+		i.Sub(i, g.ways[k][g.dfa.start])
+	}
+
+	word := make([]rune, 0, k)
+	state := g.dfa.start
+	for left := k; left > 0; left-- {
+		for _, r := range g.alphabet {
+			next := g.next(state, r)
+			w := g.ways[left-1][next]
+			if i.Cmp(w) < 0 {
+				word = append(word, r)
+				state = next
+				break
+			}
+			i.Sub(i, w)
+		}
+	}
+	return string(word), true
+}
+
+// NearMiss is a string that does not match, one edit away from one that does
+type NearMiss struct {
+	Text   string
+	From   string // the matching string it was made from
+	Failed []Rule // rules the string breaks
+}
+
+// NearMisses makes up to n different near misses by sampling matching
+// strings and substituting, inserting, deleting or swapping one rune
+func (g *Generator) NearMisses(rng *rand.Rand, n int) []NearMiss {
+	var misses []NearMiss
+	seen := make(map[string]bool)
+	for tries := 0; len(misses) < n && tries < 100*n; tries++ {
+		from, ok := g.Sample(rng)
+		if !ok {
+			break
+		}
+		word := mutate([]rune(from), g.alphabet, rng)
+		if len(word) > g.maxLen || g.matches(word) || seen[string(word)] {
+			continue
+		}
+		seen[string(word)] = true
+
+		miss := NearMiss{Text: string(word), From: from}
+		mask := g.dfa.Match(miss.Text)
+		for i, rule := range g.dfa.Rules {
+			if mask&(1<<i) == 0 {
+				miss.Failed = append(miss.Failed, rule)
+			}
+		}
+		misses = append(misses, miss)
+	}
+	return misses
+}
+
+// mutate applies one random edit to a copy of word
+func mutate(word []rune, alphabet []rune, rng *rand.Rand) []rune {
+	out := append([]rune(nil), word...)
+	r := alphabet[rng.Intn(len(alphabet))]
+	switch op := rng.Intn(4); {
+	case op == 0 && len(out) > 0:
+		out[rng.Intn(len(out))] = r
+	case op == 1 && len(out) > 0:
+		i := rng.Intn(len(out))
+		out = append(out[:i], out[i+1:]...)
+	case op == 2 && len(out) > 1:
+		i := rng.Intn(len(out) - 1)
+		out[i], out[i+1] = out[i+1], out[i]
+	default:
+		i := rng.Intn(len(out) + 1)
+		out = append(out[:i], append([]rune{r}, out[i:]...)...)
+	}
+	return out
+}
+
+// runGenerator prints what mode asks for: "count", "enumerate" (up to n
+// strings), "sample" (n strings) or "near-miss" (n negative examples)
+func runGenerator(w io.Writer, g *Generator, mode string, n int, seed int64) error {
+	out := bufio.NewWriter(w)
+	rng := rand.New(rand.NewSource(seed))
+	switch mode {
+	case "count":
+		fmt.Fprintln(out, g.Count())
+	case "enumerate":
+		printed := 0
+		g.Enumerate(func(s string) bool {
+			if printed >= n {
+				return false
+			}
+			fmt.Fprintf(out, "%q\n", s)
+			printed++
+			return true
+		})
+	case "sample":
+		for i := 0; i < n; i++ {
+			s, ok := g.Sample(rng)
+			if !ok {
+				return fmt.Errorf("no string of up to %d runes matches", g.maxLen)
+			}
+			fmt.Fprintf(out, "%q\n", s)
+		}
+	case "near-miss":
+		for _, m := range g.NearMisses(rng, n) {
+			failed := make([]string, len(m.Failed))
+			for i, rule := range m.Failed {
+				failed[i] = rule.String()
+			}
+			fmt.Fprintf(out, "%q\tfrom %q\tfails: %s\n", m.Text, m.From, strings.Join(failed, ", "))
+		}
+	default:
+		return fmt.Errorf("unknown -gen mode %q (use count, enumerate, sample or near-miss)", mode)
+	}
+	return out.Flush()
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// allStrings lists every string over alphabet of up to maxLen runes,
+// shortest first and in alphabet order within a length
+func allStrings(alphabet []rune, maxLen int) []string {
+	out := []string{""}
+	level := []string{""}
+	for k := 1; k <= maxLen; k++ {
+		var longer []string
+		for _, s := range level {
+			for _, r := range alphabet {
+				longer = append(longer, s+string(r))
+			}
+		}
+		out = append(out, longer...)
+		level = longer
+	}
+	return out
+}
+
+var (
+	acceptAll = func(rules int) func(uint64) bool {
+		return func(mask uint64) bool { return mask == 1<<rules-1 }
+	}
+	acceptAny = func(int) func(uint64) bool {
+		return func(mask uint64) bool { return mask != 0 }
+	}
+)
+
+// bruteForce checks every string with regexp and returns those accepted
+func bruteForce(rules []Rule, alphabet []rune, maxLen int, accepts func(uint64) bool) []string {
+	masks := regexpMasks(rules, true)
+	var out []string
+	for _, s := range allStrings(alphabet, maxLen) {
+		if accepts(masks(s)) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func newGenerator(t testing.TB, rules []Rule, alphabet string, maxLen int, accepts func(uint64) bool) *Generator {
+	t.Helper()
+	d, err := Compile(rules, CompileOptions{IgnoreCase: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewGenerator(d, []rune(alphabet), maxLen, accepts)
+}
+
+func TestCountMatchesBruteForce(t *testing.T) {
+	// The findian rules over a small alphabet, a count checked independently
+	g := newGenerator(t, defaultRules, "aiInx", 5, acceptAll(3))
+	if got := g.Count().String(); got != "142" {
+		t.Errorf("default rules over aiInx up to 5 runes: %s strings, want 142", got)
+	}
+
+	for _, rules := range ruleSets {
+		for mode, accept := range map[string]func(int) func(uint64) bool{"all": acceptAll, "any": acceptAny} {
+			accepts := accept(len(rules))
+			alphabet := "abcxy"
+			if strings.Contains(fmt.Sprint(rules), "é") {
+				alphabet = "éÉax"
+			}
+			for _, maxLen := range []int{0, 1, 4} {
+				want := bruteForce(rules, []rune(alphabet), maxLen, accepts)
+				g := newGenerator(t, rules, alphabet, maxLen, accepts)
+				if got := g.Count().Int64(); got != int64(len(want)) {
+					t.Errorf("%v %s up to %d: Count = %d, brute force finds %d", rules, mode, maxLen, got, len(want))
+				}
+			}
+		}
+	}
+}
+
+func TestEnumerateOrder(t *testing.T) {
+	for _, rules := range ruleSets[:6] {
+		accepts := acceptAll(len(rules))
+		want := bruteForce(rules, []rune("abinx"), 4, accepts)
+		var got []string
+		newGenerator(t, rules, "abinx", 4, accepts).Enumerate(func(s string) bool {
+			got = append(got, s)
+			return true
+		})
+		if !slices.Equal(got, want) {
+			t.Errorf("%v: enumerated %d strings, brute force %d\ngot  %q\nwant %q", rules, len(got), len(want), got, want)
+		}
+	}
+
+	// Shortest first, then in alphabet order
+	g := newGenerator(t, defaultRules, "nai", 4, acceptAll(3))
+	var got []string
+	g.Enumerate(func(s string) bool {
+		got = append(got, s)
+		return len(got) < 5
+	})
+	if want := []string{"ian", "inan", "iann", "iaan", "iain"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSample(t *testing.T) {
+	g := newGenerator(t, defaultRules, "aiInx", 5, acceptAll(3))
+	all := bruteForce(defaultRules, []rune("aiInx"), 5, acceptAll(3))
+	rng := rand.New(rand.NewSource(1))
+	seen := make(map[string]int)
+	for i := 0; i < 20*len(all); i++ {
+		s, ok := g.Sample(rng)
+		if !ok || !slices.Contains(all, s) {
+			t.Fatalf("Sample = %q, %v: not a matching string", s, ok)
+		}
+		seen[s]++
+	}
+	// Uniform over 142 strings: 20 draws each on average, so every one of
+	// them should turn up
+	if len(seen) != len(all) {
+		t.Errorf("sampled %d different strings of %d", len(seen), len(all))
+	}
+
+	// Nothing of two runes starts with i, contains a and ends with n
+	if s, ok := newGenerator(t, defaultRules, "ain", 2, acceptAll(3)).Sample(rng); ok {
+		t.Errorf("Sample with no matching strings = %q", s)
+	}
+}
+
+func TestNearMisses(t *testing.T) {
+	for _, rules := range ruleSets {
+		accepts := acceptAll(len(rules))
+		g := newGenerator(t, rules, "abinx", 6, accepts)
+		masks := regexpMasks(rules, true)
+		misses := g.NearMisses(rand.New(rand.NewSource(1)), 20)
+		if g.Count().Sign() > 0 && len(misses) == 0 {
+			t.Errorf("%v: no near misses", rules)
+		}
+		seen := make(map[string]bool)
+		for _, m := range misses {
+			mask := masks(m.Text)
+			if accepts(mask) {
+				t.Errorf("%v: near miss %q matches", rules, m.Text)
+			}
+			if !accepts(masks(m.From)) {
+				t.Errorf("%v: near miss %q comes from %q, which does not match", rules, m.Text, m.From)
+			}
+			if len([]rune(m.Text)) > 6 || seen[m.Text] {
+				t.Errorf("%v: near miss %q too long or repeated", rules, m.Text)
+			}
+			seen[m.Text] = true
+			var failed []Rule
+			for i, rule := range rules {
+				if mask&(1<<i) == 0 {
+					failed = append(failed, rule)
+				}
+			}
+			if !slices.Equal(m.Failed, failed) {
+				t.Errorf("%v: %q fails %v, want %v", rules, m.Text, m.Failed, failed)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// RuleKind says how a rule's pattern is matched against a string
+type RuleKind int
+
+const (
+	Prefix RuleKind = iota
+	Suffix
+	Contains
+	Regex
+)
+
+var ruleKindNames = []string{"prefix", "suffix", "contains", "regex"}
+
+func (k RuleKind) String() string {
+	return ruleKindNames[k]
+}
+
+// Rule is one test a string has to pass, such as "starts with i"
+type Rule struct {
+	Kind    RuleKind
+	Pattern string
+}
+
+func (r Rule) String() string {
+	return r.Kind.String() + " " + r.Pattern
+}
+
+// expr returns the rule as a regular expression
+func (r Rule) expr() string {
+	switch r.Kind {
+	case Prefix:
+		return `\A` + regexp.QuoteMeta(r.Pattern)
+	case Suffix:
+		return regexp.QuoteMeta(r.Pattern) + `\z`
+	case Contains:
+		return regexp.QuoteMeta(r.Pattern)
+	}
+	return r.Pattern
+}
+
+// defaultRules is the original findian test: starts with "i", contains "a"
+// and ends with "n"
+var defaultRules = []Rule{{Prefix, "i"}, {Contains, "a"}, {Suffix, "n"}}
+
+// ParseRule reads a rule written as "<kind> <pattern>", e.g. "prefix i" or
+// "regex [0-9]+". Everything after the first space is the pattern.
+func ParseRule(s string) (Rule, error) {
+	kind, pattern, ok := strings.Cut(strings.TrimSpace(s), " ")
+	if !ok || pattern == "" {
+		return Rule{}, fmt.Errorf("rule %q must look like \"<kind> <pattern>\"", s)
+	}
+	for i, name := range ruleKindNames {
+		if kind == name {
+			return Rule{RuleKind(i), pattern}, nil
+		}
+	}
+	return Rule{}, fmt.Errorf("unknown rule kind %q (use prefix, suffix, contains or regex)", kind)
+}
+
+// loadRules reads one rule per line; blank lines and lines starting with #
+// are skipped
+func loadRules(filename string) ([]Rule, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []Rule
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := ParseRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", filename, lineNo, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("%s: no rules", filename)
+	}
+	return rules, nil
+}
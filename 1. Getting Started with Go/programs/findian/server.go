@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limits for requests to the server
+const (
+	maxBatch       = 10000   // strings in one request
+	maxRequestBody = 8 << 20 // bytes in one HTTP request
+
+	readHeaderTimeout = 5 * time.Second
+	readTimeout       = 30 * time.Second // a full batch on a slow link
+	writeTimeout      = 30 * time.Second
+)
+
+// ruleSet is one rules file from the server's directory, compiled
+type ruleSet struct {
+	name     string
+	dfa      *DFA
+	skeleton bool
+	modTime  time.Time
+	size     int64
+
+	// Counters start again from zero whenever the file is reloaded, since
+	// the rules they count may have changed
+	evaluations atomic.Int64
+	matches     atomic.Int64 // strings that passed every rule
+	hits        []atomic.Int64
+}
+
+// match returns the bit mask of rules s satisfies and updates the counters
+func (rs *ruleSet) match(s string, ignoreCase bool) uint64 {
+	if rs.skeleton {
+		s = skeletonKey(s, ignoreCase)
+	}
+	mask := rs.dfa.Match(s)
+	rs.evaluations.Add(1)
+	for i := range rs.hits {
+		if mask&(1<<i) != 0 {
+			rs.hits[i].Add(1)
+		}
+	}
+	if mask == 1<<len(rs.dfa.Rules)-1 {
+		rs.matches.Add(1)
+	}
+	return mask
+}
+
+// matchServer evaluates strings against the rule sets in a directory. Each
+// file named <set>.rules holds one rule set in the -rules format.
+type matchServer struct {
+	dir      string
+	opts     CompileOptions
+	skeleton bool
+
+	mu     sync.RWMutex
+	sets   map[string]*ruleSet
+	broken map[string]time.Time // files that failed to load, so each error is logged once
+}
+
+func newMatchServer(dir string, opts CompileOptions, skeleton bool) (*matchServer, error) {
+	s := &matchServer{dir: dir, opts: opts, skeleton: skeleton, sets: make(map[string]*ruleSet), broken: make(map[string]time.Time)}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	if len(s.sets) == 0 {
+		return nil, fmt.Errorf("no *.rules files in %s", dir)
+	}
+	return s, nil
+}
+
+// reload compiles every rules file that is new or has changed since the last
+// call and drops sets whose file is gone. A file that fails to compile keeps
+// its previous version and the error is logged.
+func (s *matchServer) reload() error {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.rules"))
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".rules")
+		seen[name] = true
+		info, err := os.Stat(path)
+		if err != nil {
+			continue // removed since the Glob
+		}
+
+		s.mu.RLock()
+		old := s.sets[name]
+		s.mu.RUnlock()
+		if old != nil && old.modTime.Equal(info.ModTime()) && old.size == info.Size() {
+			continue
+		}
+		if t, ok := s.broken[name]; ok && t.Equal(info.ModTime()) {
+			continue
+		}
+
+		rs, err := s.compile(name, path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error loading rule set:", err)
+			s.broken[name] = info.ModTime()
+			continue
+		}
+		delete(s.broken, name)
+		rs.modTime, rs.size = info.ModTime(), info.Size()
+		s.mu.Lock()
+		s.sets[name] = rs
+		s.mu.Unlock()
+		if old != nil {
+			fmt.Fprintf(os.Stderr, "Reloaded rule set %q\n", name)
+		} else {
+			fmt.Fprintf(os.Stderr, "Loaded rule set %q\n", name)
+		}
+	}
+
+	s.mu.Lock()
+	for name := range s.sets {
+		if !seen[name] {
+			delete(s.sets, name)
+			fmt.Fprintf(os.Stderr, "Removed rule set %q\n", name)
+		}
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *matchServer) compile(name, path string) (*ruleSet, error) {
+	rules, err := loadRules(path)
+	if err != nil {
+		return nil, err
+	}
+	if s.skeleton {
+		rules = skeletonRules(rules, s.opts.IgnoreCase)
+	}
+	dfa, err := Compile(rules, s.opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &ruleSet{name: name, dfa: dfa, skeleton: s.skeleton, hits: make([]atomic.Int64, len(rules))}, nil
+}
+
+// watch calls reload every interval; there is no portable file notification
+// in the standard library, and a stat per file is cheap
+func (s *matchServer) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := s.reload(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error reloading rule sets:", err)
+		}
+	}
+}
+
+func (s *matchServer) set(name string) (*ruleSet, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rs, ok := s.sets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown rule set %q", name)
+	}
+	return rs, nil
+}
+
+// matchResult is the answer for one string
+type matchResult struct {
+	Input   string   `json:"input"`
+	Found   bool     `json:"found"`
+	Matched []string `json:"matched"` // rules the string satisfies
+
+	numbers []string // the same rules by number, from 1, for the socket
+}
+
+// evaluate matches a batch of strings against one rule set; with anyRule a
+// string is found when one rule matches rather than all of them
+func (s *matchServer) evaluate(name string, inputs []string, anyRule bool) ([]matchResult, error) {
+	rs, err := s.set(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(inputs) > maxBatch {
+		return nil, fmt.Errorf("at most %d strings per batch", maxBatch)
+	}
+
+	all := uint64(1)<<len(rs.dfa.Rules) - 1
+	results := make([]matchResult, len(inputs))
+	for i, input := range inputs {
+		mask := rs.match(input, s.opts.IgnoreCase)
+		res := matchResult{Input: input, Found: mask == all || anyRule && mask != 0, Matched: []string{}}
+		for j, rule := range rs.dfa.Rules {
+			if mask&(1<<j) != 0 {
+				res.Matched = append(res.Matched, rule.String())
+				res.numbers = append(res.numbers, strconv.Itoa(j+1))
+			}
+		}
+		results[i] = res
+	}
+	return results, nil
+}
+
+// ruleStats and setStats are the counters reported by /stats and "stats"
+type ruleStats struct {
+	Rule string `json:"rule"`
+	Hits int64  `json:"hits"`
+}
+
+type setStats struct {
+	Name        string      `json:"name"`
+	Loaded      time.Time   `json:"loaded"` // modification time of the file
+	Evaluations int64       `json:"evaluations"`
+	Matches     int64       `json:"matches"`
+	Rules       []ruleStats `json:"rules"`
+}
+
+func (s *matchServer) stats() []setStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []setStats
+	for _, rs := range s.sets {
+		st := setStats{Name: rs.name, Loaded: rs.modTime, Evaluations: rs.evaluations.Load(), Matches: rs.matches.Load()}
+		for i, rule := range rs.dfa.Rules {
+			st.Rules = append(st.Rules, ruleStats{rule.String(), rs.hits[i].Load()})
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// matchRequest is the body of POST /match
+type matchRequest struct {
+	RuleSet string   `json:"ruleset"`
+	Inputs  []string `json:"inputs"`
+	Any     bool     `json:"any"`
+}
+
+func (s *matchServer) handleMatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req matchRequest
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	results, err := s.evaluate(req.RuleSet, req.Inputs, req.Any)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]any{"results": results})
+}
+
+func (s *matchServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, map[string]any{"rulesets": s.stats()})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// serveSocket answers the line protocol on a Unix domain socket:
+//
+//	match <set> <text>      one result line
+//	batch <set> <n>         followed by n lines of text, n result lines
+//	stats                   one line per rule, then a line with "."
+//
+// A result line is "Found" or "Not Found", a tab, and the numbers of the
+// rules that matched separated by commas. Errors are "error <message>".
+func (s *matchServer) serveSocket(path string) error {
+	if err := removeStaleSocket(path); err != nil {
+		return err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// removeStaleSocket deletes the socket a previous run left at path, and
+// refuses to touch anything else that is there
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket; not removing it", path)
+	}
+	return os.Remove(path)
+}
+
+func (s *matchServer) handleConn(conn net.Conn) {
+	defer conn.Close()
+	in := bufio.NewScanner(conn)
+	in.Buffer(nil, 1<<20)
+	out := bufio.NewWriter(conn)
+	defer out.Flush()
+
+	for in.Scan() {
+		cmd, rest, _ := strings.Cut(in.Text(), " ")
+		switch cmd {
+		case "match":
+			name, text, _ := strings.Cut(rest, " ")
+			s.writeResults(out, name, []string{text})
+		case "batch":
+			name, count, _ := strings.Cut(rest, " ")
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 0 || n > maxBatch {
+				fmt.Fprintf(out, "error batch needs a count from 0 to %d\n", maxBatch)
+				break
+			}
+			inputs := make([]string, 0, n)
+			for len(inputs) < n && in.Scan() {
+				inputs = append(inputs, in.Text())
+			}
+			s.writeResults(out, name, inputs)
+		case "stats":
+			for _, st := range s.stats() {
+				fmt.Fprintf(out, "%s\tevaluations\t%d\n%s\tmatches\t%d\n", st.Name, st.Evaluations, st.Name, st.Matches)
+				for _, rule := range st.Rules {
+					fmt.Fprintf(out, "%s\t%s\t%d\n", st.Name, rule.Rule, rule.Hits)
+				}
+			}
+			fmt.Fprintln(out, ".")
+		default:
+			fmt.Fprintf(out, "error unknown command %q (use match, batch or stats)\n", cmd)
+		}
+		// Answer before waiting for the next request
+		if err := out.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *matchServer) writeResults(out *bufio.Writer, name string, inputs []string) {
+	results, err := s.evaluate(name, inputs, false)
+	if err != nil {
+		fmt.Fprintln(out, "error", err)
+		return
+	}
+	for _, res := range results {
+		fmt.Fprintf(out, "%s\t%s\n", result(res.Found), strings.Join(res.numbers, ","))
+	}
+}
+
+// runServer starts the HTTP endpoint and the socket, whichever are given
+func runServer(dir, httpAddr, socketPath string, opts CompileOptions, skeleton bool) error {
+	if httpAddr == "" && socketPath == "" {
+		return fmt.Errorf("-rules-dir needs -http or -socket")
+	}
+	s, err := newMatchServer(dir, opts, skeleton)
+	if err != nil {
+		return err
+	}
+	go s.watch(time.Second)
+
+	errs := make(chan error, 2)
+	if socketPath != "" {
+		fmt.Fprintf(os.Stderr, "Listening on unix socket %s\n", socketPath)
+		go func() { errs <- s.serveSocket(socketPath) }()
+	}
+	if httpAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/match", s.handleMatch)
+		mux.HandleFunc("/stats", s.handleStats)
+		server := &http.Server{
+			Addr:              httpAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: readHeaderTimeout,
+			ReadTimeout:       readTimeout,
+			WriteTimeout:      writeTimeout,
+		}
+		fmt.Fprintf(os.Stderr, "Listening on http://%s/match\n", httpAddr)
+		go func() { errs <- server.ListenAndServe() }()
+	}
+	return <-errs
+}
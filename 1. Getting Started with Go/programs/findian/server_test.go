@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRemoveStaleSocket(t *testing.T) {
+	dir := t.TempDir()
+
+	// A regular file is left alone
+	file := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(file, []byte("keep me"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeStaleSocket(file); err == nil {
+		t.Error("no error for a regular file")
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("regular file was removed: %v", err)
+	}
+
+	// A socket from a previous run is removed
+	sock := filepath.Join(dir, "findian.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skip("no unix sockets here:", err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	if err := removeStaleSocket(sock); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(sock); !os.IsNotExist(err) {
+		t.Errorf("socket still there: %v", err)
+	}
+
+	if err := removeStaleSocket(filepath.Join(dir, "missing.sock")); err != nil {
+		t.Errorf("missing path: %v", err)
+	}
+}
+
+// newTestServer writes the rules files into a new directory and serves it,
+// ignoring case as findian does by default
+func newTestServer(t *testing.T, files map[string]string) *matchServer {
+	t.Helper()
+	dir := t.TempDir()
+	for name, rules := range files {
+		writeRules(t, dir, name, rules)
+	}
+	s, err := newMatchServer(dir, CompileOptions{IgnoreCase: true}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// writeRules writes <name>.rules with a modification time that changes on
+// every call, so reload sees the change even on coarse file systems
+func writeRules(t *testing.T, dir, name, rules string) {
+	t.Helper()
+	path := filepath.Join(dir, name+".rules")
+	old, err := os.Stat(path)
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err == nil {
+		later := old.ModTime().Add(time.Second)
+		os.Chtimes(path, later, later)
+	}
+}
+
+const ianRules = "# the findian test\nprefix i\ncontains a\nsuffix n\n"
+
+func postMatch(t *testing.T, s *matchServer, body string) (*httptest.ResponseRecorder, []matchResult) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.handleMatch(rec, httptest.NewRequest(http.MethodPost, "/match", strings.NewReader(body)))
+	var resp struct {
+		Results []matchResult `json:"results"`
+	}
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: %v", rec.Body, err)
+		}
+	}
+	return rec, resp.Results
+}
+
+func TestHTTPMatch(t *testing.T) {
+	s := newTestServer(t, map[string]string{"ian": ianRules})
+
+	rec, results := postMatch(t, s, `{"ruleset": "ian", "inputs": ["Ian", "in", "xyz"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type %q", ct)
+	}
+	want := []matchResult{
+		{Input: "Ian", Found: true, Matched: []string{"prefix i", "contains a", "suffix n"}},
+		{Input: "in", Found: false, Matched: []string{"prefix i", "suffix n"}},
+		{Input: "xyz", Found: false, Matched: []string{}},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("got %+v, want %+v", results, want)
+	}
+
+	// With any, one rule is enough
+	_, results = postMatch(t, s, `{"ruleset": "ian", "inputs": ["Ian", "in", "xyz"], "any": true}`)
+	var found []bool
+	for _, res := range results {
+		found = append(found, res.Found)
+	}
+	if want := []bool{true, true, false}; !reflect.DeepEqual(found, want) {
+		t.Errorf("any: found %v, want %v", found, want)
+	}
+}
+
+func TestHTTPMatchRejects(t *testing.T) {
+	s := newTestServer(t, map[string]string{"ian": ianRules})
+	tooMany, _ := json.Marshal(matchRequest{RuleSet: "ian", Inputs: make([]string, maxBatch+1)})
+	full, _ := json.Marshal(matchRequest{RuleSet: "ian", Inputs: make([]string, maxBatch)})
+
+	tests := []struct {
+		name, body string
+		code       int
+	}{
+		{"bad JSON", `{"ruleset": `, http.StatusBadRequest},
+		{"unknown set", `{"ruleset": "nope", "inputs": ["ian"]}`, http.StatusBadRequest},
+		{"batch too big", string(tooMany), http.StatusBadRequest},
+		{"largest batch", string(full), http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rec, _ := postMatch(t, s, tt.body); rec.Code != tt.code {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, rec.Code, tt.code, strings.TrimSpace(rec.Body.String()))
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleMatch(rec, httptest.NewRequest(http.MethodGet, "/match", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "POST" {
+		t.Errorf("GET /match: status %d, Allow %q", rec.Code, rec.Header().Get("Allow"))
+	}
+	rec = httptest.NewRecorder()
+	s.handleStats(rec, httptest.NewRequest(http.MethodPost, "/stats", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET" {
+		t.Errorf("POST /stats: status %d, Allow %q", rec.Code, rec.Header().Get("Allow"))
+	}
+}
+
+func getStats(t *testing.T, s *matchServer) []setStats {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.handleStats(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	var resp struct {
+		RuleSets []setStats `json:"rulesets"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s: %v", rec.Body, err)
+	}
+	return resp.RuleSets
+}
+
+func TestHTTPStats(t *testing.T) {
+	s := newTestServer(t, map[string]string{"ian": ianRules, "digits": "regex [0-9]\n"})
+	postMatch(t, s, `{"ruleset": "ian", "inputs": ["Ian", "in", "xyz", "iAN"]}`)
+	postMatch(t, s, `{"ruleset": "ian", "inputs": ["an"], "any": true}`)
+
+	stats := getStats(t, s)
+	if len(stats) != 2 || stats[0].Name != "digits" || stats[1].Name != "ian" {
+		t.Fatalf("got %+v, want digits and ian in order", stats)
+	}
+	if d := stats[0]; d.Evaluations != 0 || d.Matches != 0 || d.Rules[0].Hits != 0 {
+		t.Errorf("unused set has counts: %+v", d)
+	}
+	ian := stats[1]
+	if ian.Evaluations != 5 || ian.Matches != 2 {
+		t.Errorf("%d evaluations and %d matches, want 5 and 2", ian.Evaluations, ian.Matches)
+	}
+	want := []ruleStats{{"prefix i", 3}, {"contains a", 3}, {"suffix n", 4}}
+	if !reflect.DeepEqual(ian.Rules, want) {
+		t.Errorf("rule hits %+v, want %+v", ian.Rules, want)
+	}
+	if ian.Loaded.IsZero() {
+		t.Error("no load time")
+	}
+}
+
+func TestSocketProtocol(t *testing.T) {
+	s := newTestServer(t, map[string]string{"ian": ianRules})
+	sock := filepath.Join(t.TempDir(), "findian.sock")
+	go s.serveSocket(sock)
+
+	var conn net.Conn
+	var err error
+	for try := 0; try < 100; try++ {
+		if conn, err = net.Dial("unix", sock); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Skip("no unix sockets here:", err)
+	}
+	defer conn.Close()
+	in := bufio.NewScanner(conn)
+	ask := func(request string, lines int) []string {
+		t.Helper()
+		fmt.Fprint(conn, request)
+		var got []string
+		for len(got) < lines && in.Scan() {
+			got = append(got, in.Text())
+		}
+		return got
+	}
+
+	tests := []struct {
+		request string
+		want    []string
+	}{
+		{"match ian Ian\n", []string{"Found\t1,2,3"}},
+		{"match ian in\n", []string{"Not Found\t1,3"}},
+		{"match ian\n", []string{"Not Found\t"}},
+		{"batch ian 3\nian\nxyz\nIvan\n", []string{"Found\t1,2,3", "Not Found\t", "Found\t1,2,3"}},
+		{"batch ian 0\n", nil},
+		{"match nope ian\n", []string{`error unknown rule set "nope"`}},
+		{"batch ian many\n", []string{"error batch needs a count from 0 to 10000"}},
+		{"batch ian 10001\n", []string{"error batch needs a count from 0 to 10000"}},
+		{"hello\n", []string{`error unknown command "hello" (use match, batch or stats)`}},
+		{"stats\n", []string{
+			"ian\tevaluations\t6", "ian\tmatches\t3",
+			"ian\tprefix i\t4", "ian\tcontains a\t3", "ian\tsuffix n\t4",
+			".",
+		}},
+	}
+	for _, tt := range tests {
+		if got := ask(tt.request, len(tt.want)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.request, got, tt.want)
+		}
+	}
+}
+
+func TestHotReload(t *testing.T) {
+	s := newTestServer(t, map[string]string{"ian": ianRules})
+	postMatch(t, s, `{"ruleset": "ian", "inputs": ["Ian"]}`)
+
+	// A changed file is picked up and its counters start again
+	writeRules(t, s.dir, "ian", "prefix x\n")
+	if err := s.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, results := postMatch(t, s, `{"ruleset": "ian", "inputs": ["Ian", "Xan"]}`); len(results) != 2 ||
+		results[0].Found || !results[1].Found {
+		t.Errorf("after reload: %+v, want only Xan found", results)
+	}
+	if st := getStats(t, s)[0]; st.Evaluations != 2 || len(st.Rules) != 1 {
+		t.Errorf("after reload: stats %+v, want 2 evaluations of one rule", st)
+	}
+
+	// A broken file keeps the rules that were loaded before it
+	writeRules(t, s.dir, "ian", "prefix x\nbogus rule\n")
+	if err := s.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, results := postMatch(t, s, `{"ruleset": "ian", "inputs": ["Xan"]}`); len(results) != 1 || !results[0].Found {
+		t.Errorf("after a broken reload: %+v, want the previous rules", results)
+	}
+	if _, ok := s.broken["ian"]; !ok {
+		t.Error("broken file not remembered")
+	}
+
+	// New and removed files add and drop sets
+	writeRules(t, s.dir, "new", "suffix n\n")
+	os.Remove(filepath.Join(s.dir, "ian.rules"))
+	if err := s.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if stats := getStats(t, s); len(stats) != 1 || stats[0].Name != "new" {
+		t.Errorf("after adding and removing files: %+v, want only new", stats)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token is a piece of the input a rule is applied to, with its byte offsets
+type Token struct {
+	Text       string
+	Start, End int
+}
+
+// Tokenizer splits a line into words
+type Tokenizer func(s string) []Token
+
+// tokenizers are the word splitters -tokenize can choose
+var tokenizers = map[string]Tokenizer{
+	"whitespace": WhitespaceTokens,
+	"unicode":    UnicodeWordTokens,
+}
+
+// WhitespaceTokens splits s at spaces, keeping punctuation with the words
+// the way strings.Fields does: "ian, is" gives "ian," and "is"
+func WhitespaceTokens(s string) []Token {
+	var tokens []Token
+	start := -1
+	for i, r := range s {
+		switch {
+		case unicode.IsSpace(r) && start >= 0:
+			tokens = append(tokens, Token{s[start:i], start, i})
+			start = -1
+		case !unicode.IsSpace(r) && start < 0:
+			start = i
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, Token{s[start:], start, len(s)})
+	}
+	return tokens
+}
+
+// isWordRune reports whether r belongs inside a word
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Mc, r) || r == '_'
+}
+
+// UnicodeWordTokens finds words roughly the way Unicode word boundaries
+// (UAX #29) do: runs of letters, digits and marks, where an apostrophe or
+// dot between two letters ("don't", "e.g") and a comma or dot between two
+// digits ("3.14", "1,000") stay inside the word. Other punctuation and
+// spaces separate words and are dropped.
+func UnicodeWordTokens(s string) []Token {
+	var tokens []Token
+	start := -1
+	var prev rune
+	for i, r := range s {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			prev = r
+			continue
+		}
+		if start >= 0 {
+			_, width := utf8.DecodeRuneInString(s[i:])
+			next, _ := utf8.DecodeRuneInString(s[i+width:])
+			letters := unicode.IsLetter(prev) && unicode.IsLetter(next) && strings.ContainsRune("'’.:·", r)
+			digits := unicode.IsDigit(prev) && unicode.IsDigit(next) && strings.ContainsRune(",.;", r)
+			if letters || digits {
+				continue
+			}
+			tokens = append(tokens, Token{s[start:i], start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, Token{s[start:], start, len(s)})
+	}
+	return tokens
+}
+
+// isSentenceEnd reports whether r can end a sentence
+func isSentenceEnd(r rune) bool {
+	return strings.ContainsRune(".!?…。！？", r)
+}
+
+// SentenceTokens splits s after ".", "!" or "?" when a space or the end of
+// the line follows, so "3.14" stays whole. The end marks and surrounding
+// spaces are trimmed from each sentence.
+func SentenceTokens(s string) []Token {
+	var tokens []Token
+	start := 0
+	emit := func(end int) {
+		text := strings.TrimRightFunc(s[start:end], func(r rune) bool {
+			return unicode.IsSpace(r) || isSentenceEnd(r)
+		})
+		trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+		if trimmed != "" {
+			offset := start + len(text) - len(trimmed)
+			tokens = append(tokens, Token{trimmed, offset, offset + len(trimmed)})
+		}
+		start = end
+	}
+	for i, r := range s {
+		if !isSentenceEnd(r) {
+			continue
+		}
+		end := i + utf8.RuneLen(r)
+		if next, _ := utf8.DecodeRuneInString(s[end:]); end == len(s) || unicode.IsSpace(next) {
+			emit(end)
+		}
+	}
+	emit(len(s))
+	return tokens
+}
+
+// Scope says what a rule is applied to
+type Scope string
+
+const (
+	ScopeLine     Scope = "line"
+	ScopeWord     Scope = "word"
+	ScopeSentence Scope = "sentence"
+)
+
+// splitter returns the function that cuts a line into tokens for a scope
+func splitter(scope Scope, tokenize string) (Tokenizer, error) {
+	switch scope {
+	case ScopeLine:
+		return func(s string) []Token { return []Token{{s, 0, len(s)}} }, nil
+	case ScopeSentence:
+		return SentenceTokens, nil
+	case ScopeWord:
+		if t, ok := tokenizers[tokenize]; ok {
+			return t, nil
+		}
+		return nil, fmt.Errorf("unknown tokenizer %q (use whitespace or unicode)", tokenize)
+	}
+	return nil, fmt.Errorf("unknown scope %q (use line, word or sentence)", scope)
+}
+
+// MatchTokens applies match to every token of s and returns those it accepts
+func MatchTokens(s string, split Tokenizer, match func(string) bool) []Token {
+	var matched []Token
+	for _, t := range split(s) {
+		if match(t.Text) {
+			matched = append(matched, t)
+		}
+	}
+	return matched
+}
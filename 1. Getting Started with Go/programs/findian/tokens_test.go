@@ -0,0 +1,86 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTokenizers(t *testing.T) {
+	tests := []struct {
+		name  string
+		split Tokenizer
+		in    string
+		want  []Token
+	}{
+		{"whitespace", WhitespaceTokens, "ian, is  here", []Token{{"ian,", 0, 4}, {"is", 5, 7}, {"here", 9, 13}}},
+		{"whitespace edges", WhitespaceTokens, "\t ian x ", []Token{{"ian", 2, 5}, {"x", 7, 8}}},
+		{"whitespace empty", WhitespaceTokens, "   ", nil},
+
+		{"unicode punctuation", UnicodeWordTokens, "ian, (is) here!", []Token{{"ian", 0, 3}, {"is", 6, 8}, {"here", 10, 14}}},
+		{"unicode apostrophe", UnicodeWordTokens, "don't stop", []Token{{"don't", 0, 5}, {"stop", 6, 10}}},
+		{"unicode curly apostrophe", UnicodeWordTokens, "don’t", []Token{{"don’t", 0, 7}}},
+		{"unicode decimals", UnicodeWordTokens, "pi is 3.14.", []Token{{"pi", 0, 2}, {"is", 3, 5}, {"3.14", 6, 10}}},
+		{"unicode thousands", UnicodeWordTokens, "1,000, 2", []Token{{"1,000", 0, 5}, {"2", 7, 8}}},
+		{"unicode no join across space", UnicodeWordTokens, "a. b 1, 2", []Token{{"a", 0, 1}, {"b", 3, 4}, {"1", 5, 6}, {"2", 8, 9}}},
+		{"unicode trailing apostrophe", UnicodeWordTokens, "ians'", []Token{{"ians", 0, 4}}},
+		{"unicode non-Latin", UnicodeWordTokens, "Иван, é_x", []Token{{"Иван", 0, 8}, {"é_x", 10, 14}}},
+
+		{"sentences", SentenceTokens, "Ian is here. Is Ian? Yes!", []Token{{"Ian is here", 0, 11}, {"Is Ian", 13, 19}, {"Yes", 21, 24}}},
+		{"sentence keeps decimals", SentenceTokens, "Pi is 3.14 or so.", []Token{{"Pi is 3.14 or so", 0, 16}}},
+		{"sentence trims", SentenceTokens, "  Wait...  Go!!  ", []Token{{"Wait", 2, 6}, {"Go", 11, 13}}},
+		{"sentence without end mark", SentenceTokens, "one. two", []Token{{"one", 0, 3}, {"two", 5, 8}}},
+		{"sentence ellipsis", SentenceTokens, "So… yes", []Token{{"So", 0, 2}, {"yes", 6, 9}}},
+		{"sentence only marks", SentenceTokens, " ?! ", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.split(tt.in)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			for _, tok := range got {
+				if tt.in[tok.Start:tok.End] != tok.Text {
+					t.Errorf("token %q has offsets %d-%d, which hold %q", tok.Text, tok.Start, tok.End, tt.in[tok.Start:tok.End])
+				}
+			}
+		})
+	}
+}
+
+func TestMatchTokens(t *testing.T) {
+	d, err := Compile(defaultRules, CompileOptions{IgnoreCase: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := "Ian is here. In Sudan? Where is Ivan"
+	tests := []struct {
+		scope    Scope
+		tokenize string
+		want     []string
+	}{
+		{ScopeLine, "", []string{line}},
+		{ScopeWord, "whitespace", []string{"Ian", "Ivan"}},
+		{ScopeWord, "unicode", []string{"Ian", "Ivan"}},
+		{ScopeSentence, "", []string{"In Sudan"}},
+	}
+	for _, tt := range tests {
+		split, err := splitter(tt.scope, tt.tokenize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, tok := range MatchTokens(line, split, d.MatchAll) {
+			got = append(got, tok.Text)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s/%s: matched %q, want %q", tt.scope, tt.tokenize, got, tt.want)
+		}
+	}
+
+	if _, err := splitter(ScopeWord, "bogus"); err == nil {
+		t.Error("unknown tokenizer: no error")
+	}
+	if _, err := splitter("paragraph", ""); err == nil {
+		t.Error("unknown scope: no error")
+	}
+}
@@ -0,0 +1,288 @@
+// Package intervals stores sets of integers as sorted runs such as 1-5, and
+// reads and writes them as range expressions like "1-5, 8, 10-12"
+package intervals
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Interval is the run of integers from Lo to Hi, both included
+type Interval struct {
+	Lo, Hi int
+}
+
+// Len returns how many integers the interval holds. The count is unsigned
+// because a run can be longer than the largest int; the one run it cannot
+// count, every int there is, gives math.MaxUint64.
+func (iv Interval) Len() uint64 {
+	return addCounts(uint64(iv.Hi-iv.Lo), 1) // the difference wraps into range
+}
+
+// addCounts adds two counts, stopping at math.MaxUint64 instead of wrapping
+func addCounts(a, b uint64) uint64 {
+	if a > math.MaxUint64-b {
+		return math.MaxUint64
+	}
+	return a + b
+}
+
+// String shows a single value as "8" and a run as "1-5"
+func (iv Interval) String() string {
+	if iv.Lo == iv.Hi {
+		return strconv.Itoa(iv.Lo)
+	}
+	return fmt.Sprintf("%d-%d", iv.Lo, iv.Hi)
+}
+
+// IntervalSet is a set of integers stored as sorted runs, so 1 2 3 4 5 takes
+// one Interval instead of five ints. Runs never overlap or touch: adding 6
+// to 1-5 makes 1-6.
+type IntervalSet struct {
+	runs []Interval
+}
+
+// FromInts builds a set from the values in nums, which need not be sorted
+// or distinct
+func FromInts(nums []int) *IntervalSet {
+	sorted := slices.Clone(nums)
+	sort.Ints(sorted)
+	s := &IntervalSet{}
+	for _, n := range slices.Compact(sorted) {
+		if k := len(s.runs); k > 0 && s.runs[k-1].Hi+1 == n {
+			s.runs[k-1].Hi = n
+		} else {
+			s.runs = append(s.runs, Interval{n, n})
+		}
+	}
+	return s
+}
+
+// FromIntervals builds a set from runs that may overlap, touch or come in
+// any order
+func FromIntervals(runs []Interval) *IntervalSet {
+	s := &IntervalSet{}
+	for _, run := range runs {
+		s.Add(run.Lo, run.Hi)
+	}
+	return s
+}
+
+// ParseIntervals reads a range expression such as "1-5, 8, 10-12" back into
+// a set. Items are separated by commas; negative bounds are allowed, as in
+// "-3--1". Overlapping items are merged.
+func ParseIntervals(expr string) (*IntervalSet, error) {
+	s := &IntervalSet{}
+	if strings.TrimSpace(expr) == "" {
+		return s, nil
+	}
+	for _, item := range strings.Split(expr, ",") {
+		iv, err := ParseInterval(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		s.Add(iv.Lo, iv.Hi)
+	}
+	return s, nil
+}
+
+// ParseInterval reads one "n" or "lo-hi" item. The dash between the bounds
+// is the first one after the first character, so a leading minus sign
+// belongs to lo.
+func ParseInterval(item string) (Interval, error) {
+	if item == "" {
+		return Interval{}, fmt.Errorf("empty range in list")
+	}
+	lo, hi := item, item
+	if i := strings.Index(item[1:], "-"); i >= 0 {
+		lo, hi = strings.TrimSpace(item[:i+1]), strings.TrimSpace(item[i+2:])
+	}
+	a, err1 := strconv.Atoi(lo)
+	b, err2 := strconv.Atoi(hi)
+	if err1 != nil || err2 != nil {
+		return Interval{}, fmt.Errorf("%q is not a number or a range like 1-5", item)
+	}
+	if a > b {
+		return Interval{}, fmt.Errorf("range %q runs backwards", item)
+	}
+	return Interval{a, b}, nil
+}
+
+// Add puts every integer from lo to hi into the set, merging the runs it
+// overlaps or touches
+func (s *IntervalSet) Add(lo, hi int) {
+	if lo > hi {
+		return
+	}
+	// Runs i..j-1 overlap [lo, hi] or sit right next to it. Nothing sits
+	// next to the ends of the int range.
+	below, above := lo, hi
+	if lo > math.MinInt {
+		below = lo - 1
+	}
+	if hi < math.MaxInt {
+		above = hi + 1
+	}
+	i := sort.Search(len(s.runs), func(k int) bool { return s.runs[k].Hi >= below })
+	j := sort.Search(len(s.runs), func(k int) bool { return s.runs[k].Lo > above })
+	if i < j {
+		lo = min(lo, s.runs[i].Lo)
+		hi = max(hi, s.runs[j-1].Hi)
+	}
+	s.runs = slices.Replace(s.runs, i, j, Interval{lo, hi})
+}
+
+// Remove takes every integer from lo to hi out of the set, splitting a run
+// that only partly overlaps, and returns how many were there
+func (s *IntervalSet) Remove(lo, hi int) uint64 {
+	if lo > hi {
+		return 0
+	}
+	i := sort.Search(len(s.runs), func(k int) bool { return s.runs[k].Hi >= lo })
+	j := sort.Search(len(s.runs), func(k int) bool { return s.runs[k].Lo > hi })
+	if i == j {
+		return 0
+	}
+	var removed uint64
+	for _, run := range s.runs[i:j] {
+		removed = addCounts(removed, Interval{max(run.Lo, lo), min(run.Hi, hi)}.Len())
+	}
+	var keep []Interval
+	if first := s.runs[i]; first.Lo < lo {
+		keep = append(keep, Interval{first.Lo, lo - 1})
+	}
+	if last := s.runs[j-1]; last.Hi > hi {
+		keep = append(keep, Interval{hi + 1, last.Hi})
+	}
+	s.runs = slices.Replace(s.runs, i, j, keep...)
+	return removed
+}
+
+// Contains reports whether v is in the set
+func (s *IntervalSet) Contains(v int) bool {
+	i := sort.Search(len(s.runs), func(k int) bool { return s.runs[k].Hi >= v })
+	return i < len(s.runs) && s.runs[i].Lo <= v
+}
+
+// Intervals returns a copy of the runs, in order
+func (s *IntervalSet) Intervals() []Interval {
+	return slices.Clone(s.runs)
+}
+
+// Len returns how many integers the set holds, counted as Interval.Len
+// counts them
+func (s *IntervalSet) Len() uint64 {
+	var n uint64
+	for _, run := range s.runs {
+		n = addCounts(n, run.Len())
+	}
+	return n
+}
+
+// Each calls fn with every integer in order until fn returns false
+func (s *IntervalSet) Each(fn func(int) bool) {
+	for _, run := range s.runs {
+		// Stop at Hi before incrementing, which would wrap at math.MaxInt
+		for v := run.Lo; ; v++ {
+			if !fn(v) {
+				return
+			}
+			if v == run.Hi {
+				break
+			}
+		}
+	}
+}
+
+// Gaps returns the runs of missing integers between the smallest and the
+// largest value
+func (s *IntervalSet) Gaps() []Interval {
+	if len(s.runs) == 0 {
+		return nil
+	}
+	return s.GapsIn(s.runs[0].Lo, s.runs[len(s.runs)-1].Hi)
+}
+
+// GapsIn returns the runs of integers from lo to hi that are missing from
+// the set
+func (s *IntervalSet) GapsIn(lo, hi int) []Interval {
+	var gaps []Interval
+	next := lo // the first integer not yet looked at
+	for _, run := range s.runs {
+		if run.Hi < lo {
+			continue
+		}
+		if run.Lo > hi {
+			break
+		}
+		if run.Lo > next {
+			gaps = append(gaps, Interval{next, run.Lo - 1})
+		}
+		if run.Hi >= hi {
+			return gaps // also keeps run.Hi + 1 from wrapping
+		}
+		next = run.Hi + 1
+	}
+	if next <= hi {
+		gaps = append(gaps, Interval{next, hi})
+	}
+	return gaps
+}
+
+// Coverage describes how much of its span a set fills
+type Coverage struct {
+	Values     uint64   // integers in the set
+	Runs       int      // runs they form
+	Span       Interval // smallest to largest value
+	Missing    uint64   // integers in the span that are not in the set
+	Gaps       int      // runs of missing integers
+	LongestRun Interval
+	LongestGap Interval // only set when Gaps > 0
+}
+
+// Percent returns the share of the span the set covers
+func (c Coverage) Percent() float64 {
+	if c.Values == 0 {
+		return 0
+	}
+	return 100 * float64(c.Values) / float64(c.Span.Len())
+}
+
+// Coverage works out the coverage statistics of the set
+func (s *IntervalSet) Coverage() Coverage {
+	var c Coverage
+	if len(s.runs) == 0 {
+		return c
+	}
+	c.Runs = len(s.runs)
+	c.Span = Interval{s.runs[0].Lo, s.runs[len(s.runs)-1].Hi}
+	for i, run := range s.runs {
+		c.Values = addCounts(c.Values, run.Len())
+		if i == 0 || run.Len() > c.LongestRun.Len() {
+			c.LongestRun = run
+		}
+	}
+	gaps := s.Gaps()
+	c.Gaps = len(gaps)
+	for i, gap := range gaps {
+		c.Missing = addCounts(c.Missing, gap.Len())
+		if i == 0 || gap.Len() > c.LongestGap.Len() {
+			c.LongestGap = gap
+		}
+	}
+	return c
+}
+
+// String shows the set as a range expression, "1-5, 8, 10-12", which
+// ParseIntervals reads back
+func (s *IntervalSet) String() string {
+	parts := make([]string, len(s.runs))
+	for i, run := range s.runs {
+		parts[i] = run.String()
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,220 @@
+package intervals
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestParseIntervals(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []Interval
+	}{
+		{"1-5, 8, 10-12", []Interval{{1, 5}, {8, 8}, {10, 12}}},
+		{"-3--1", []Interval{{-3, -1}}},
+		{"-3 - -1, 0", []Interval{{-3, 0}}},
+		{"10-12,1-5,3-9", []Interval{{1, 12}}},
+		{"", nil},
+		{"  ", nil},
+	}
+	for _, tt := range tests {
+		s, err := ParseIntervals(tt.expr)
+		if err != nil {
+			t.Errorf("%q: %v", tt.expr, err)
+			continue
+		}
+		if got := s.Intervals(); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseIntervalsRejectsBadItems(t *testing.T) {
+	for _, expr := range []string{
+		"5-1",    // backwards
+		"-1--3",  // backwards with negative bounds
+		"1-5,,8", // empty item
+		"1-5, ",  // empty last item
+		"a",
+		"1-",
+		"1-5-7",
+	} {
+		if _, err := ParseIntervals(expr); err == nil {
+			t.Errorf("%q: no error", expr)
+		}
+	}
+}
+
+func TestAddMerges(t *testing.T) {
+	tests := []struct {
+		name string
+		adds []Interval
+		want []Interval
+	}{
+		{"separate", []Interval{{1, 2}, {5, 6}}, []Interval{{1, 2}, {5, 6}}},
+		{"touching after", []Interval{{1, 5}, {6, 6}}, []Interval{{1, 6}}},
+		{"touching before", []Interval{{5, 8}, {1, 4}}, []Interval{{1, 8}}},
+		{"overlapping", []Interval{{1, 5}, {3, 9}}, []Interval{{1, 9}}},
+		{"inside", []Interval{{1, 9}, {3, 4}}, []Interval{{1, 9}}},
+		{"bridging", []Interval{{1, 2}, {6, 7}, {10, 12}, {3, 9}}, []Interval{{1, 12}}},
+		{"backwards ignored", []Interval{{1, 2}, {5, 4}}, []Interval{{1, 2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &IntervalSet{}
+			for _, iv := range tt.adds {
+				s.Add(iv.Lo, iv.Hi)
+			}
+			if got := s.Intervals(); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveSplitsRun(t *testing.T) {
+	s := FromInts([]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	if n := s.Remove(4, 6); n != 3 {
+		t.Errorf("removed %d, want 3", n)
+	}
+	if got, want := s.Intervals(), []Interval{{1, 3}, {7, 10}}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if s.Contains(5) || !s.Contains(3) || !s.Contains(7) {
+		t.Errorf("Contains wrong after split: %v", s)
+	}
+	// Across both runs and the gap between them
+	if n := s.Remove(2, 8); n != 4 {
+		t.Errorf("removed %d, want 4", n)
+	}
+	if got, want := s.Intervals(), []Interval{{1, 1}, {9, 10}}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if n := s.Remove(3, 8); n != 0 {
+		t.Errorf("removed %d from a gap, want 0", n)
+	}
+}
+
+func TestGapsIn(t *testing.T) {
+	s, _ := ParseIntervals("3-5, 9, 12-14")
+	tests := []struct {
+		lo, hi int
+		want   []Interval
+	}{
+		{3, 14, []Interval{{6, 8}, {10, 11}}},
+		{0, 20, []Interval{{0, 2}, {6, 8}, {10, 11}, {15, 20}}},
+		{4, 9, []Interval{{6, 8}}},
+		{12, 13, nil},
+		{6, 8, []Interval{{6, 8}}},
+	}
+	for _, tt := range tests {
+		if got := s.GapsIn(tt.lo, tt.hi); !slices.Equal(got, tt.want) {
+			t.Errorf("GapsIn(%d, %d) = %v, want %v", tt.lo, tt.hi, got, tt.want)
+		}
+	}
+	if got := (&IntervalSet{}).Gaps(); got != nil {
+		t.Errorf("empty set has gaps %v", got)
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	s, _ := ParseIntervals("1-5, 8, 10-12")
+	want := Coverage{
+		Values:     9,
+		Runs:       3,
+		Span:       Interval{1, 12},
+		Missing:    3,
+		Gaps:       2,
+		LongestRun: Interval{1, 5},
+		LongestGap: Interval{6, 7},
+	}
+	if got := s.Coverage(); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if p := s.Coverage().Percent(); p != 75 {
+		t.Errorf("Percent = %v, want 75", p)
+	}
+	if c := (&IntervalSet{}).Coverage(); c != (Coverage{}) || c.Percent() != 0 {
+		t.Errorf("empty set coverage %+v", c)
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	for _, nums := range [][]int{
+		{1, 2, 3, 4, 5, 8, 10, 11, 12},
+		{-3, -2, -1, 1},
+		{-10, -8, 0, 7},
+		{42},
+		nil,
+	} {
+		s := FromInts(nums)
+		back, err := ParseIntervals(s.String())
+		if err != nil {
+			t.Errorf("%q: %v", s, err)
+			continue
+		}
+		if !slices.Equal(back.Intervals(), s.Intervals()) {
+			t.Errorf("%q read back as %q", s, back)
+		}
+	}
+	if got := FromInts([]int{5, 1, 3, 2, 4, 4}).String(); got != "1-5" {
+		t.Errorf("String = %q, want \"1-5\"", got)
+	}
+}
+
+func TestIntLimits(t *testing.T) {
+	all := &IntervalSet{}
+	all.Add(math.MinInt, math.MaxInt)
+	if n := all.Len(); n != math.MaxUint64 {
+		t.Errorf("every int: Len = %d, want the saturated %d", n, uint64(math.MaxUint64))
+	}
+	s, err := ParseIntervals("0-9223372036854775807")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Len(); n != 1<<63 {
+		t.Errorf("0 to MaxInt: Len = %d, want %d", n, uint64(1<<63))
+	}
+
+	ends := FromInts([]int{math.MinInt, math.MaxInt})
+	if got, want := ends.Gaps(), []Interval{{math.MinInt + 1, math.MaxInt - 1}}; !slices.Equal(got, want) {
+		t.Errorf("Gaps = %v, want %v", got, want)
+	}
+	if got := all.Gaps(); got != nil {
+		t.Errorf("every int has gaps %v", got)
+	}
+	if c := ends.Coverage(); c.Values != 2 || c.Missing != math.MaxUint64-1 {
+		t.Errorf("coverage %+v, want 2 values and %d missing", c, uint64(math.MaxUint64-1))
+	}
+
+	// Adding next to the ends must not wrap around to the other end
+	top := &IntervalSet{}
+	top.Add(math.MaxInt, math.MaxInt)
+	top.Add(math.MinInt, math.MinInt)
+	top.Add(math.MaxInt-2, math.MaxInt-1)
+	want := []Interval{{math.MinInt, math.MinInt}, {math.MaxInt - 2, math.MaxInt}}
+	if got := top.Intervals(); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if n := top.Remove(math.MinInt, math.MaxInt); n != 4 {
+		t.Errorf("removed %d, want 4", n)
+	}
+
+	var got []int
+	FromInts([]int{math.MaxInt - 1, math.MaxInt}).Each(func(v int) bool {
+		got = append(got, v)
+		return len(got) < 5
+	})
+	if want := []int{math.MaxInt - 1, math.MaxInt}; !slices.Equal(got, want) {
+		t.Errorf("Each gave %v, want %v", got, want)
+	}
+	got = nil
+	all.Each(func(v int) bool {
+		got = append(got, v)
+		return len(got) < 3
+	})
+	if want := []int{math.MinInt, math.MinInt + 1, math.MinInt + 2}; !slices.Equal(got, want) {
+		t.Errorf("Each gave %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// cborCodec implements CBOR (RFC 8949) for the record types
+type cborCodec struct{}
+
+// CBOR major types, stored in the top three bits of the first byte
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+)
+
+func (cborCodec) Encode(w io.Writer, v any) error {
+	v, err := normalize(v)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	if err := encodeCBOR(bw, v); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// writeCBORHead writes a major type with its argument in the shortest form
+func writeCBORHead(w *bufio.Writer, major byte, arg uint64) {
+	m := major << 5
+	switch {
+	case arg < 24:
+		w.WriteByte(m | byte(arg))
+	case arg <= math.MaxUint8:
+		w.WriteByte(m | 24)
+		w.WriteByte(byte(arg))
+	case arg <= math.MaxUint16:
+		w.WriteByte(m | 25)
+		binary.Write(w, binary.BigEndian, uint16(arg))
+	case arg <= math.MaxUint32:
+		w.WriteByte(m | 26)
+		binary.Write(w, binary.BigEndian, uint32(arg))
+	default:
+		w.WriteByte(m | 27)
+		binary.Write(w, binary.BigEndian, arg)
+	}
+}
+
+func encodeCBOR(w *bufio.Writer, v any) error {
+	switch v := v.(type) {
+	case nil:
+		w.WriteByte(0xf6)
+	case bool:
+		if v {
+			w.WriteByte(0xf5)
+		} else {
+			w.WriteByte(0xf4)
+		}
+	case int64:
+		if v >= 0 {
+			writeCBORHead(w, cborUint, uint64(v))
+		} else {
+			writeCBORHead(w, cborNegInt, uint64(-1-v))
+		}
+	case float64:
+		w.WriteByte(cborSimple<<5 | 27)
+		binary.Write(w, binary.BigEndian, math.Float64bits(v))
+	case string:
+		writeCBORHead(w, cborText, uint64(len(v)))
+		w.WriteString(v)
+	case []any:
+		writeCBORHead(w, cborArray, uint64(len(v)))
+		for _, item := range v {
+			if err := encodeCBOR(w, item); err != nil {
+				return err
+			}
+		}
+	case *OrderedMap:
+		writeCBORHead(w, cborMap, uint64(len(v.Keys())))
+		for _, k := range v.Keys() {
+			value, _ := v.Get(k)
+			writeCBORHead(w, cborText, uint64(len(k)))
+			w.WriteString(k)
+			if err := encodeCBOR(w, value); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cbor: cannot encode %T", v)
+	}
+	return nil
+}
+
+func (cborCodec) Decode(r io.Reader) (any, error) {
+	return decodeCBOR(bufio.NewReader(r))
+}
+
+// errCBORBreak marks the 0xff that ends an indefinite-length item
+var errCBORBreak = errors.New("cbor: unexpected break")
+
+// readCBORHead reads the first byte of an item and its argument. For
+// indefinite lengths it returns indefinite = true.
+func readCBORHead(r *bufio.Reader) (major byte, info byte, arg uint64, indefinite bool, err error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, 0, false, err
+	}
+	major, info = b>>5, b&0x1f
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info <= 27:
+		buf := make([]byte, 1<<(info-24))
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return 0, 0, 0, false, err
+		}
+		for _, c := range buf {
+			arg = arg<<8 | uint64(c)
+		}
+	case info == 31:
+		indefinite = true
+	default:
+		return 0, 0, 0, false, fmt.Errorf("cbor: reserved additional info %d", info)
+	}
+	return major, info, arg, indefinite, nil
+}
+
+func decodeCBOR(r *bufio.Reader) (any, error) {
+	major, info, arg, indefinite, err := readCBORHead(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUint:
+		if arg > math.MaxInt64 {
+			return float64(arg), nil
+		}
+		return int64(arg), nil
+	case cborNegInt:
+		if arg > math.MaxInt64 {
+			return -1 - float64(arg), nil
+		}
+		return -1 - int64(arg), nil
+	case cborBytes, cborText:
+		data, err := readCBORString(r, major, arg, indefinite)
+		if err != nil {
+			return nil, err
+		}
+		// Byte strings have no counterpart in JSON, so they become text
+		return string(data), nil
+	case cborArray:
+		list := []any{}
+		for i := uint64(0); indefinite || i < arg; i++ {
+			item, err := decodeCBOR(r)
+			if err == errCBORBreak && indefinite {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, nil
+	case cborMap:
+		m := NewOrderedMap()
+		for i := uint64(0); indefinite || i < arg; i++ {
+			key, err := decodeCBOR(r)
+			if err == errCBORBreak && indefinite {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeCBOR(r)
+			if err != nil {
+				return nil, err
+			}
+			m.Set(fmt.Sprint(key), value)
+		}
+		return m, nil
+	case cborTag:
+		// Tags such as dates only add meaning; keep the tagged value
+		return decodeCBOR(r)
+	}
+
+	// Major type 7: simple values and floats
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil // null and undefined
+	case 25:
+		return float16ToFloat64(uint16(arg)), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(arg))), nil
+	case 27:
+		return math.Float64frombits(arg), nil
+	case 31:
+		return nil, errCBORBreak
+	}
+	return nil, fmt.Errorf("cbor: unsupported simple value %d", arg)
+}
+
+// readCBORString reads a definite string, or the chunks of an indefinite one
+func readCBORString(r *bufio.Reader, major byte, length uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		if length > 1<<30 {
+			return nil, fmt.Errorf("cbor: string of %d bytes is too long", length)
+		}
+		data := make([]byte, length)
+		_, err := io.ReadFull(r, data)
+		return data, err
+	}
+
+	var data []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == 0xff {
+			return data, nil
+		}
+		r.UnreadByte()
+		chunkMajor, _, chunkLen, chunkIndef, err := readCBORHead(r)
+		if err != nil {
+			return nil, err
+		}
+		if chunkMajor != major || chunkIndef {
+			return nil, fmt.Errorf("cbor: bad chunk in indefinite-length string")
+		}
+		chunk, err := readCBORString(r, major, chunkLen, false)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, chunk...)
+	}
+}
+
+// float16ToFloat64 decodes an IEEE 754 half-precision float
+func float16ToFloat64(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var val float64
+	switch exp {
+	case 0:
+		val = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			val = math.Inf(1)
+		} else {
+			val = math.NaN()
+		}
+	default:
+		val = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -val
+	}
+	return val
+}
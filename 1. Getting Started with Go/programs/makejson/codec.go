@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Codec writes and reads records in one data format.
+//
+// Records are built from a small set of types that every format can hold:
+// nil, bool, int64, float64, string, []any and *OrderedMap.
+type Codec interface {
+	Encode(w io.Writer, v any) error
+	Decode(r io.Reader) (any, error)
+}
+
+// codecs lists the formats by name, for -format and convert
+var codecs = map[string]Codec{
+	"json":    jsonCodec{},
+	"xml":     xmlCodec{},
+	"cbor":    cborCodec{},
+	"msgpack": msgpackCodec{},
+	"proto":   protoCodec{},
+}
+
+// codecFor looks up a codec by name, or by file extension when name is empty
+func codecFor(name, filename string) (Codec, string, error) {
+	if name == "" {
+		name = strings.TrimPrefix(filepath.Ext(filename), ".")
+		switch name {
+		case "mp", "msgp":
+			name = "msgpack"
+		case "pb", "bin":
+			name = "proto"
+		}
+	}
+	c, ok := codecs[name]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown format %q (use json, xml, cbor, msgpack or proto)", name)
+	}
+	return c, name, nil
+}
+
+// normalize converts the Go values a caller might pass in (plain maps,
+// ints, string slices) into the record types the codecs understand
+func normalize(v any) (any, error) {
+	switch v := v.(type) {
+	case nil, bool, int64, float64, string:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case float32:
+		return float64(v), nil
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			n, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = n
+		}
+		return out, nil
+	case []string:
+		out := make([]any, len(v))
+		for i, s := range v {
+			out[i] = s
+		}
+		return out, nil
+	case *OrderedMap:
+		out := NewOrderedMap()
+		for _, k := range v.Keys() {
+			value, _ := v.Get(k)
+			n, err := normalize(value)
+			if err != nil {
+				return nil, err
+			}
+			out.Set(k, n)
+		}
+		return out, nil
+	case map[string]string:
+		m := make(map[string]any, len(v))
+		for k, s := range v {
+			m[k] = s
+		}
+		return normalize(m)
+	case map[string]any:
+		// Plain maps have no order, so use the same sorted order as encoding/json
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := NewOrderedMap()
+		for _, k := range keys {
+			n, err := normalize(v[k])
+			if err != nil {
+				return nil, err
+			}
+			out.Set(k, n)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("cannot encode value of type %T", v)
+	}
+}
+
+// jsonCodec reads and writes JSON, keeping object keys in order
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(w io.Writer, v any) error {
+	return NewJSONEncoder(w, EncodeOptions{TrailingNewline: true}).Encode(v)
+}
+
+func (jsonCodec) Decode(r io.Reader) (any, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	dec.UseNumber()
+	return decodeJSONValue(dec)
+}
+
+// decodeJSONValue reads one value token by token, so objects come back as
+// OrderedMaps with their keys in the original order
+func decodeJSONValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			m := NewOrderedMap()
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				m.Set(keyTok.(string), value)
+			}
+			_, err := dec.Token() // closing brace
+			return m, err
+		case '[':
+			list := []any{}
+			for dec.More() {
+				value, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, value)
+			}
+			_, err := dec.Token() // closing bracket
+			return list, err
+		}
+		return nil, fmt.Errorf("unexpected %v", t)
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		return t.Float64()
+	default:
+		return t, nil // string, bool or nil
+	}
+}
+
+// xmlCodec writes records as XML elements: object keys become element names
+// and list items become <item> elements. Keys that are not valid XML names,
+// such as "first name", are written as <field name="first name">. XML has
+// no types, so everything reads back as strings, lists and OrderedMaps.
+type xmlCodec struct{}
+
+// xmlRoot is the name of the outermost element
+const xmlRoot = "contact"
+
+func (xmlCodec) Encode(w io.Writer, v any) error {
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := encodeXMLValue(enc, xmlRoot, v); err != nil {
+		return err
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// xmlField is the element used for keys that cannot be element names
+const xmlField = "field"
+
+// validXMLName reports whether key can be used as an element name as it is.
+// Names starting with "xml" are reserved, and colons would mean a namespace.
+func validXMLName(key string) bool {
+	if key == "" || strings.HasPrefix(strings.ToLower(key), "xml") {
+		return false
+	}
+	for i, r := range key {
+		switch {
+		case unicode.IsLetter(r) || r == '_':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// xmlStart returns the start element for a key
+func xmlStart(key string) xml.StartElement {
+	if validXMLName(key) {
+		return xml.StartElement{Name: xml.Name{Local: key}}
+	}
+	return xml.StartElement{
+		Name: xml.Name{Local: xmlField},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: key}},
+	}
+}
+
+func encodeXMLValue(enc *xml.Encoder, name string, v any) error {
+	start := xmlStart(name)
+	switch v := v.(type) {
+	case *OrderedMap:
+		if len(v.Keys()) == 0 {
+			// Without this an empty object would read back as ""
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "object"}, Value: "true"})
+		}
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, k := range v.Keys() {
+			value, _ := v.Get(k)
+			if err := encodeXMLValue(enc, k, value); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	case []any:
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "list"}, Value: "true"})
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := encodeXMLValue(enc, "item", item); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	case nil:
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "nil"}, Value: "true"})
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		return enc.EncodeToken(start.End())
+	default:
+		return enc.EncodeElement(fmt.Sprint(v), start)
+	}
+}
+
+func (xmlCodec) Decode(r io.Reader) (any, error) {
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return decodeXMLElement(dec, start)
+		}
+	}
+}
+
+// decodeXMLElement reads the content of start up to its end element
+func decodeXMLElement(dec *xml.Decoder, start xml.StartElement) (any, error) {
+	isList, isNil, isObject := false, false, false
+	for _, a := range start.Attr {
+		isList = isList || (a.Name.Local == "list" && a.Value == "true")
+		isNil = isNil || (a.Name.Local == "nil" && a.Value == "true")
+		isObject = isObject || (a.Name.Local == "object" && a.Value == "true")
+	}
+
+	var text strings.Builder
+	var children *OrderedMap
+	list := []any{}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			value, err := decodeXMLElement(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			if isList {
+				list = append(list, value)
+				continue
+			}
+			if children == nil {
+				children = NewOrderedMap()
+			}
+			children.Set(xmlKey(t), value)
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			switch {
+			case isNil:
+				return nil, nil
+			case isList:
+				return list, nil
+			case children != nil:
+				return children, nil
+			case isObject:
+				return NewOrderedMap(), nil
+			}
+			return text.String(), nil
+		}
+	}
+}
+
+// xmlKey returns the object key an element stands for
+func xmlKey(start xml.StartElement) string {
+	if start.Name.Local == xmlField {
+		for _, a := range start.Attr {
+			if a.Name.Local == "name" {
+				return a.Value
+			}
+		}
+	}
+	return start.Name.Local
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sampleRecord has every type the codecs must carry, plus keys that are not
+// valid XML names and an empty object
+func sampleRecord() *OrderedMap {
+	phone := NewOrderedMap()
+	phone.Set("type", "mobile")
+	phone.Set("number", "+1 555 0100")
+
+	m := NewOrderedMap()
+	m.Set("name", "José García")
+	m.Set("first name", "José")
+	m.Set("2fa", true)
+	m.Set("age", int64(42))
+	m.Set("id", int64(1)<<53+1)
+	m.Set("score", 1.5)
+	m.Set("nickname", nil)
+	m.Set("phones", []any{phone})
+	m.Set("tags", []any{})
+	m.Set("extra", NewOrderedMap())
+	return m
+}
+
+func roundTrip(t *testing.T, c Codec, v any) any {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := c.Encode(&buf, v); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	back, err := c.Decode(&buf)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return back
+}
+
+// dump shows a record with its types, since OrderedMaps print as pointers
+func dump(v any) string {
+	switch v := v.(type) {
+	case *OrderedMap:
+		parts := make([]string, len(v.Keys()))
+		for i, k := range v.Keys() {
+			value, _ := v.Get(k)
+			parts[i] = fmt.Sprintf("%q: %s", k, dump(value))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = dump(item)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	default:
+		return fmt.Sprintf("%T(%v)", v, v)
+	}
+}
+
+func TestBinaryCodecsRoundTrip(t *testing.T) {
+	for _, name := range []string{"json", "cbor", "msgpack"} {
+		t.Run(name, func(t *testing.T) {
+			want := sampleRecord()
+			if got := roundTrip(t, codecs[name], want); !reflect.DeepEqual(got, any(want)) {
+				t.Errorf("got  %s\nwant %s", dump(got), dump(want))
+			}
+		})
+	}
+}
+
+func TestJSONThroughCBOR(t *testing.T) {
+	input := `{"name":"Zoë","age":7,"ratio":0.25,"ok":false,"none":null,"list":[1,"two",{}],"empty":{}}` + "\n"
+
+	record, err := jsonCodec{}.Decode(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cbor bytes.Buffer
+	if err := (cborCodec{}).Encode(&cbor, record); err != nil {
+		t.Fatal(err)
+	}
+	back, err := cborCodec{}.Decode(&cbor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := (jsonCodec{}).Encode(&out, back); err != nil {
+		t.Fatal(err)
+	}
+	var compact bytes.Buffer
+	if err := NewJSONEncoder(&compact, EncodeOptions{TrailingNewline: true}).Encode(record); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != compact.String() {
+		t.Errorf("JSON -> CBOR -> JSON gave\n%s\nwant\n%s", out.String(), compact.String())
+	}
+}
+
+// stringify turns every scalar into its text form, which is what an XML
+// round trip gives back
+func stringify(v any) any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = stringify(item)
+		}
+		return out
+	case *OrderedMap:
+		out := NewOrderedMap()
+		for _, k := range v.Keys() {
+			value, _ := v.Get(k)
+			out.Set(k, stringify(value))
+		}
+		return out
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+	record := sampleRecord()
+	got := roundTrip(t, xmlCodec{}, record)
+	if want := stringify(record); !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %s\nwant %s", dump(got), dump(want))
+	}
+}
+
+func TestXMLOutputIsWellFormed(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (xmlCodec{}).Encode(&buf, sampleRecord()); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{`<field name="first name">`, `<field name="2fa">`, `<extra object="true"></extra>`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output has no %s:\n%s", want, out)
+		}
+	}
+
+	// Reading every token fails on malformed XML such as <first name>
+	dec := xml.NewDecoder(&buf)
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("output is not well-formed XML: %v\n%s", err, out)
+		}
+	}
+}
+
+func TestValidXMLName(t *testing.T) {
+	tests := map[string]bool{
+		"name":       true,
+		"first_name": true,
+		"phone-2":    true,
+		"Straße":     true,
+		"first name": false,
+		"2fa":        false,
+		"":           false,
+		"a:b":        false,
+		"xmlns":      false,
+		"-x":         false,
+	}
+	for key, want := range tests {
+		if got := validXMLName(key); got != want {
+			t.Errorf("validXMLName(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
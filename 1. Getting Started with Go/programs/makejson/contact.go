@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+)
+
+// LabeledValue is one phone number, email address or postal address with a
+// label such as "mobile", "work" or "home"
+type LabeledValue struct {
+	Label string
+	Value string
+
+	protoUnknown []byte // fields from a newer LabeledValue message, see proto.go
+}
+
+// Contact is a person with any number of phones, emails and addresses
+type Contact struct {
+	Name      string
+	Phones    []LabeledValue // E.164, e.g. +15551234567
+	Emails    []LabeledValue
+	Addresses []LabeledValue // labeled home, work or other
+
+	protoUnknown []byte // fields from a newer Contact message, see proto.go
+}
+
+// protoUnknownKey holds protobuf fields this version doesn't know, base64
+// encoded, so they survive a conversion to another format and back
+const protoUnknownKey = "_proto_unknown"
+
+// addressLabels are the labels allowed for postal addresses
+var addressLabels = []string{"home", "work", "other"}
+
+// NormalizePhone checks a phone number and returns it in E.164 form: a "+",
+// the country code and the number, at most 15 digits in all. Spaces, dots,
+// dashes and parentheses are ignored, "00" is read as "+", and numbers
+// without either get defaultCountry (e.g. "1" or "44") in front. The "(0)"
+// some write after the country code, as in "+44 (0) 20...", is dropped since
+// the trunk 0 is not dialled from abroad.
+func NormalizePhone(s, defaultCountry string) (string, error) {
+	text := strings.TrimSpace(s)
+	international := strings.HasPrefix(text, "+") || strings.HasPrefix(text, "00")
+	if international {
+		text = strings.Replace(text, "(0)", "", 1)
+	}
+
+	var digits strings.Builder
+	for i, r := range text {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+		case strings.ContainsRune(" .-()/", r):
+		default:
+			return "", fmt.Errorf("%q is not a phone number", s)
+		}
+	}
+
+	number := digits.String()
+	switch {
+	case strings.HasPrefix(text, "+"):
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	default:
+		if defaultCountry == "" {
+			return "", fmt.Errorf("%q needs a country code, e.g. +1 or +44", s)
+		}
+		// A national trunk prefix 0 is dropped once the country code is added
+		number = defaultCountry + strings.TrimPrefix(number, "0")
+	}
+
+	if number == "" || number[0] == '0' {
+		return "", fmt.Errorf("%q has no valid country code", s)
+	}
+	// The shortest numbers in use, on St Helena (+290) and Niue (+683), have
+	// four digits after the country code
+	if len(number) < 7 || len(number) > 15 {
+		return "", fmt.Errorf("%q must have between 7 and 15 digits with the country code", s)
+	}
+	return "+" + number, nil
+}
+
+// NormalizeEmail checks the syntax of an email address against RFC 5322 and
+// returns the bare address with the domain in lower case
+func NormalizeEmail(s string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(s))
+	if err != nil {
+		return "", fmt.Errorf("%q is not a valid email address", s)
+	}
+	at := strings.LastIndexByte(addr.Address, '@')
+	if at < 0 {
+		return "", fmt.Errorf("%q is not a valid email address", s)
+	}
+	return addr.Address[:at] + "@" + strings.ToLower(addr.Address[at+1:]), nil
+}
+
+// NormalizeAddressLabel accepts home, work and other in any case
+func NormalizeAddressLabel(label string) (string, error) {
+	label = strings.ToLower(strings.TrimSpace(label))
+	for _, l := range addressLabels {
+		if label == l {
+			return label, nil
+		}
+	}
+	return "", fmt.Errorf("address label must be one of %s", strings.Join(addressLabels, ", "))
+}
+
+// Record returns the contact as an OrderedMap, with the keys in prompt order
+func (c Contact) Record() *OrderedMap {
+	list := func(values []LabeledValue, key string) []any {
+		out := []any{}
+		for _, v := range values {
+			item := NewOrderedMap()
+			item.Set("label", v.Label)
+			item.Set(key, v.Value)
+			if len(v.protoUnknown) > 0 {
+				item.Set(protoUnknownKey, base64.StdEncoding.EncodeToString(v.protoUnknown))
+			}
+			out = append(out, item)
+		}
+		return out
+	}
+
+	info := NewOrderedMap()
+	info.Set("name", c.Name)
+	info.Set("phones", list(c.Phones, "number"))
+	info.Set("emails", list(c.Emails, "address"))
+	info.Set("addresses", list(c.Addresses, "address"))
+	if len(c.protoUnknown) > 0 {
+		info.Set(protoUnknownKey, base64.StdEncoding.EncodeToString(c.protoUnknown))
+	}
+	return info
+}
+
+// contactFromRecord reads a contact back from a decoded record. A single
+// "address" string, as older versions wrote, becomes a home address.
+func contactFromRecord(v any) (Contact, error) {
+	record, ok := v.(*OrderedMap)
+	if !ok {
+		return Contact{}, fmt.Errorf("a contact must be an object, not %T", v)
+	}
+
+	var c Contact
+	for _, k := range record.Keys() {
+		value, _ := record.Get(k)
+		var err error
+		switch k {
+		case "name":
+			c.Name, ok = value.(string)
+		case "address":
+			var s string
+			if s, ok = value.(string); ok && s != "" {
+				c.Addresses = append(c.Addresses, LabeledValue{Label: "home", Value: s})
+			}
+		case protoUnknownKey:
+			c.protoUnknown, err = decodeProtoUnknown(value)
+		case "phones":
+			c.Phones, err = labeledList(value, "number")
+		case "emails":
+			c.Emails, err = labeledList(value, "address")
+		case "addresses":
+			var more []LabeledValue
+			more, err = labeledList(value, "address")
+			c.Addresses = append(c.Addresses, more...)
+		default:
+			return Contact{}, fmt.Errorf("unknown contact field %q", k)
+		}
+		if err != nil {
+			return Contact{}, err
+		}
+		if !ok {
+			return Contact{}, fmt.Errorf("contact field %q must be a string", k)
+		}
+	}
+	return c, nil
+}
+
+// labeledList reads a list of {"label": ..., key: ...} objects
+func labeledList(v any, key string) ([]LabeledValue, error) {
+	items, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected a list, not %T", v)
+	}
+	var out []LabeledValue
+	for _, item := range items {
+		m, ok := item.(*OrderedMap)
+		if !ok {
+			return nil, fmt.Errorf("expected an object in the list, not %T", item)
+		}
+		label, _ := m.Get("label")
+		value, _ := m.Get(key)
+		ls, ok1 := label.(string)
+		vs, ok2 := value.(string)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("list items need string \"label\" and %q fields", key)
+		}
+		lv := LabeledValue{Label: ls, Value: vs}
+		if unknown, ok := m.Get(protoUnknownKey); ok {
+			var err error
+			if lv.protoUnknown, err = decodeProtoUnknown(unknown); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, lv)
+	}
+	return out, nil
+}
+
+// decodeProtoUnknown reads back the protoUnknownKey field of a record
+func decodeProtoUnknown(v any) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("%q must be a base64 string", protoUnknownKey)
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %v", protoUnknownKey, err)
+	}
+	return b, nil
+}
+
+// sortedRecord copies a record into plain maps so encoding/json writes every
+// object, nested ones included, with its keys sorted
+func sortedRecord(v any) any {
+	switch v := v.(type) {
+	case *OrderedMap:
+		m := make(map[string]any)
+		for _, k := range v.Keys() {
+			value, _ := v.Get(k)
+			m[k] = sortedRecord(value)
+		}
+		return m
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = sortedRecord(item)
+		}
+		return out
+	}
+	return v
+}
+
+// contactPrompter asks for a contact on a terminal
+type contactPrompter struct {
+	in             *bufio.Reader
+	out            io.Writer
+	defaultCountry string
+}
+
+// ask prints a prompt and returns the trimmed answer; at end of input it
+// returns "" so every loop finishes
+func (p *contactPrompter) ask(prompt string) string {
+	fmt.Fprint(p.out, prompt)
+	answer, _ := p.in.ReadString('\n')
+	return strings.TrimSpace(answer)
+}
+
+// Prompt asks for the name and then any number of phones, emails and
+// addresses, asking again whenever a value doesn't validate
+func (p *contactPrompter) Prompt() Contact {
+	var c Contact
+	c.Name = p.ask("Enter your name: ")
+
+	fmt.Fprintln(p.out, "Phone numbers (leave the label empty when done):")
+	c.Phones = p.askList("  Label (mobile, home, work...): ", "  Number: ", nil, func(s string) (string, error) {
+		return NormalizePhone(s, p.defaultCountry)
+	})
+
+	fmt.Fprintln(p.out, "Email addresses (leave the label empty when done):")
+	c.Emails = p.askList("  Label (personal, work...): ", "  Email: ", nil, NormalizeEmail)
+
+	fmt.Fprintln(p.out, "Addresses (leave the label empty when done):")
+	c.Addresses = p.askList("  Label (home, work or other): ", "  Address: ", NormalizeAddressLabel, func(s string) (string, error) {
+		if s == "" {
+			return "", fmt.Errorf("address must not be empty")
+		}
+		return s, nil
+	})
+	return c
+}
+
+// askList reads label and value pairs until the label is left empty
+func (p *contactPrompter) askList(labelPrompt, valuePrompt string, checkLabel, checkValue func(string) (string, error)) []LabeledValue {
+	var values []LabeledValue
+	for {
+		label := p.ask(labelPrompt)
+		if label == "" {
+			return values
+		}
+		if checkLabel != nil {
+			var err error
+			if label, err = checkLabel(label); err != nil {
+				fmt.Fprintln(p.out, "  Invalid:", err)
+				continue
+			}
+		}
+
+		for {
+			raw := p.ask(valuePrompt)
+			value, err := checkValue(raw)
+			if err == nil {
+				values = append(values, LabeledValue{Label: label, Value: value})
+				break
+			}
+			fmt.Fprintln(p.out, "  Invalid:", err)
+			if raw == "" {
+				break // give up on this entry rather than loop at end of input
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		in, country, want string
+	}{
+		{"+44 (0) 20 7946 0958", "", "+442079460958"},
+		{"0044 (0)20 7946 0958", "", "+442079460958"},
+		{"+1 (555) 123-4567", "", "+15551234567"},
+		{"020 7946 0958", "44", "+442079460958"},
+		{"(020) 7946 0958", "44", "+442079460958"},
+		{"555.123.4567", "1", "+15551234567"},
+		{"+290 2 2345", "", "+29022345"},
+		{"+683 4002", "", "+6834002"},
+		{"4002", "683", "+6834002"},
+	}
+	for _, tt := range tests {
+		got, err := NormalizePhone(tt.in, tt.country)
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizePhone(%q, %q) = %q, %v, want %q", tt.in, tt.country, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"555-1234", "+12", "+683 400", "+1234567890123456", "+0 20 7946 0958", "call me", "+1 555 123 4567 ext 8"} {
+		if got, err := NormalizePhone(bad, ""); err == nil {
+			t.Errorf("NormalizePhone(%q) = %q, want an error", bad, got)
+		}
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"ann@example.com", "ann@example.com"},
+		{"  Ann.Lee@Example.COM ", "Ann.Lee@example.com"},
+		{"Ann Lee <ann@Example.org>", "ann@example.org"},
+		{"first.last+tag@sub.example.co.uk", "first.last+tag@sub.example.co.uk"},
+		{`"odd@local"@example.com`, `odd@local@example.com`},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeEmail(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizeEmail(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "ann", "ann@", "@example.com", "ann@@example.com", "ann example.com", "ann@exa mple.com"} {
+		if got, err := NormalizeEmail(bad); err == nil {
+			t.Errorf("NormalizeEmail(%q) = %q, want an error", bad, got)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// convertCommand implements "convert [-from fmt] [-to fmt] [-o out] [in]".
+// Formats default to the file extensions; stdin and stdout are used when no
+// file is given.
+func convertCommand(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	from := fs.String("from", "", "input format: json, xml, cbor, msgpack or proto")
+	to := fs.String("to", "", "output format: json, xml, cbor, msgpack or proto")
+	output := fs.String("o", "", "output file (default stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: convert [flags] [input]")
+	}
+
+	input := fs.Arg(0)
+	decoder, _, err := codecFor(*from, input)
+	if err != nil {
+		return err
+	}
+	encoder, _, err := codecFor(*to, *output)
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if input != "" {
+		file, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	record, err := decoder.Decode(in)
+	if err != nil {
+		return fmt.Errorf("reading input: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := encoder.Encode(&buf, record); err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(*output, buf.Bytes(), 0644)
+}
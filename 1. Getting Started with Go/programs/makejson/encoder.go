@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// OrderedMap is a JSON object that keeps its keys in insertion order, unlike
+// map[string]string which encoding/json always writes sorted
+type OrderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+// NewOrderedMap returns an empty OrderedMap
+func NewOrderedMap() *OrderedMap {
+	return &OrderedMap{values: make(map[string]any)}
+}
+
+// Set adds or replaces a key; new keys go at the end
+func (m *OrderedMap) Set(key string, value any) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Get returns the value for key
+func (m *OrderedMap) Get(key string) (any, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+// Keys returns the keys in insertion order
+func (m *OrderedMap) Keys() []string {
+	return m.keys
+}
+
+// MarshalJSON writes the keys in insertion order. HTML escaping is left to
+// the encoder that called it, which applies its own setting to the result.
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	buf.WriteByte('{')
+	for i, k := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := enc.Encode(k); err != nil {
+			return nil, err
+		}
+		buf.Truncate(buf.Len() - 1) // drop the newline Encode adds
+		buf.WriteByte(':')
+		if err := enc.Encode(m.values[k]); err != nil {
+			return nil, err
+		}
+		buf.Truncate(buf.Len() - 1)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// EncodeOptions controls how JSONEncoder writes values
+type EncodeOptions struct {
+	Indent          int  // spaces per level; 0 writes compact JSON
+	EscapeHTML      bool // escape <, > and & so the JSON is safe inside HTML
+	TrailingNewline bool
+	Color           bool // ANSI colors for keys, strings, numbers and literals
+}
+
+// JSONEncoder wraps json.Encoder with indentation, HTML escaping, newline
+// and color options
+type JSONEncoder struct {
+	w    io.Writer
+	opts EncodeOptions
+}
+
+// NewJSONEncoder returns an encoder writing to w
+func NewJSONEncoder(w io.Writer, opts EncodeOptions) *JSONEncoder {
+	return &JSONEncoder{w: w, opts: opts}
+}
+
+// Encode writes v as JSON
+func (e *JSONEncoder) Encode(v any) error {
+	data, err := e.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+// Marshal returns v as JSON formatted according to the options
+func (e *JSONEncoder) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(e.opts.EscapeHTML)
+	if e.opts.Indent > 0 {
+		enc.SetIndent("", strings.Repeat(" ", e.opts.Indent))
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	// json.Encoder always ends with a newline
+	data := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	if e.opts.Color {
+		data = colorize(data)
+	}
+	if e.opts.TrailingNewline {
+		data = append(data, '\n')
+	}
+	return data, nil
+}
+
+// ANSI color codes used by colorize
+const (
+	colorReset   = "\x1b[0m"
+	colorKey     = "\x1b[34;1m" // bold blue
+	colorString  = "\x1b[32m"   // green
+	colorNumber  = "\x1b[36m"   // cyan
+	colorLiteral = "\x1b[35m"   // magenta, for true, false and null
+)
+
+// colorize adds ANSI colors to valid JSON text
+func colorize(data []byte) []byte {
+	var out bytes.Buffer
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '"':
+			end := i + 1
+			for end < len(data) && data[end] != '"' {
+				if data[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end++ // include the closing quote
+
+			// A string followed by a colon is an object key
+			next := end
+			for next < len(data) && (data[next] == ' ' || data[next] == '\n') {
+				next++
+			}
+			color := colorString
+			if next < len(data) && data[next] == ':' {
+				color = colorKey
+			}
+			out.WriteString(color)
+			out.Write(data[i:end])
+			out.WriteString(colorReset)
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i
+			for end < len(data) && strings.IndexByte("+-.eE0123456789", data[end]) >= 0 {
+				end++
+			}
+			out.WriteString(colorNumber)
+			out.Write(data[i:end])
+			out.WriteString(colorReset)
+			i = end
+		case c == 't' || c == 'f' || c == 'n':
+			end := i
+			for end < len(data) && data[end] >= 'a' && data[end] <= 'z' {
+				end++
+			}
+			out.WriteString(colorLiteral)
+			out.Write(data[i:end])
+			out.WriteString(colorReset)
+			i = end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes()
+}
+
+// useColor decides the -color flag: "always", "never" or "auto", which
+// colors only when f is a terminal and NO_COLOR is not set
+func useColor(mode string, f *os.File) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+	default:
+		return false, fmt.Errorf("unknown -color %q (use auto, always or never)", mode)
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false, nil
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0, nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOrderedMapKeepsOrder(t *testing.T) {
+	m := NewOrderedMap()
+	m.Set("name", "Ann")
+	m.Set("address", "1 Main St")
+	m.Set("age", 30)
+	m.Set("name", "Ann Lee") // replacing keeps the key where it was
+
+	inner := NewOrderedMap()
+	inner.Set("z", true)
+	inner.Set("a", nil)
+	m.Set("extra", inner)
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"name":"Ann Lee","address":"1 Main St","age":30,"extra":{"z":true,"a":null}}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+	if got := strings.Join(m.Keys(), ","); got != "name,address,age,extra" {
+		t.Errorf("Keys = %s", got)
+	}
+	if v, ok := m.Get("age"); !ok || v != 30 {
+		t.Errorf("Get(age) = %v, %v", v, ok)
+	}
+	if _, ok := m.Get("missing"); ok {
+		t.Error("Get(missing) found something")
+	}
+	if data, _ := json.Marshal(NewOrderedMap()); string(data) != "{}" {
+		t.Errorf("empty map: %s", data)
+	}
+}
+
+// encode runs a JSONEncoder with opts and returns what it wrote
+func encode(t *testing.T, v any, opts EncodeOptions) string {
+	t.Helper()
+	var out strings.Builder
+	if err := NewJSONEncoder(&out, opts).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestEncoderIndent(t *testing.T) {
+	m := NewOrderedMap()
+	m.Set("name", "Ann")
+	inner := NewOrderedMap()
+	inner.Set("label", "home")
+	inner.Set("tags", []string{"a", "b"})
+	m.Set("addresses", []any{inner})
+	m.Set("empty", []string{})
+
+	want := `{
+  "name": "Ann",
+  "addresses": [
+    {
+      "label": "home",
+      "tags": [
+        "a",
+        "b"
+      ]
+    }
+  ],
+  "empty": []
+}`
+	if got := encode(t, m, EncodeOptions{Indent: 2}); got != want {
+		t.Errorf("indent 2:\n%s\nwant:\n%s", got, want)
+	}
+	if got := encode(t, m, EncodeOptions{}); got != `{"name":"Ann","addresses":[{"label":"home","tags":["a","b"]}],"empty":[]}` {
+		t.Errorf("compact: %s", got)
+	}
+}
+
+func TestEncoderEscapeHTML(t *testing.T) {
+	m := NewOrderedMap()
+	m.Set("note", "<b>Tom & Jerry</b>")
+	tests := []struct {
+		name   string
+		v      any
+		escape bool
+		want   string
+	}{
+		{"ordered map escaped", m, true, `{"note":"\u003cb\u003eTom \u0026 Jerry\u003c/b\u003e"}`},
+		{"ordered map raw", m, false, `{"note":"<b>Tom & Jerry</b>"}`},
+		{"plain map escaped", map[string]string{"k": "a<b&c"}, true, `{"k":"a\u003cb\u0026c"}`},
+		{"plain map raw", map[string]string{"k": "a<b&c"}, false, `{"k":"a<b&c"}`},
+	}
+	for _, tt := range tests {
+		if got := encode(t, tt.v, EncodeOptions{EscapeHTML: tt.escape}); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEncoderTrailingNewline(t *testing.T) {
+	if got := encode(t, 1, EncodeOptions{TrailingNewline: true}); got != "1\n" {
+		t.Errorf("with newline: %q", got)
+	}
+	if got := encode(t, 1, EncodeOptions{}); got != "1" {
+		t.Errorf("without newline: %q", got)
+	}
+	if got := encode(t, []int{}, EncodeOptions{Indent: 4, TrailingNewline: true}); got != "[]\n" {
+		t.Errorf("indented with newline: %q", got)
+	}
+}
+
+func TestColorize(t *testing.T) {
+	paint := func(color, s string) string { return color + s + colorReset }
+	tests := []struct{ in, want string }{
+		{`{"k":"v"}`, "{" + paint(colorKey, `"k"`) + ":" + paint(colorString, `"v"`) + "}"},
+		{`[-1.5e+3,0,true,false,null]`, "[" + paint(colorNumber, "-1.5e+3") + "," + paint(colorNumber, "0") + "," +
+			paint(colorLiteral, "true") + "," + paint(colorLiteral, "false") + "," + paint(colorLiteral, "null") + "]"},
+		// Escaped quotes and colons inside strings are not structure
+		{`["a\"b:", "c\\"]`, "[" + paint(colorString, `"a\"b:"`) + ", " + paint(colorString, `"c\\"`) + "]"},
+		// Indented output puts a space before the value, not the colon
+		{"{\n  \"k\": 1\n}", "{\n  " + paint(colorKey, `"k"`) + ": " + paint(colorNumber, "1") + "\n}"},
+	}
+	for _, tt := range tests {
+		if got := string(colorize([]byte(tt.in))); got != tt.want {
+			t.Errorf("colorize(%s) =\n%q\nwant\n%q", tt.in, got, tt.want)
+		}
+	}
+
+	got := encode(t, map[string]int{"n": 1}, EncodeOptions{Color: true, TrailingNewline: true})
+	if want := "{" + paint(colorKey, `"n"`) + ":" + paint(colorNumber, "1") + "}\n"; got != want {
+		t.Errorf("Color option: %q, want %q", got, want)
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "out.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	t.Setenv("NO_COLOR", "")
+
+	tests := []struct {
+		mode string
+		want bool
+	}{
+		{"always", true},
+		{"never", false},
+		{"auto", false}, // a file is not a terminal
+	}
+	for _, tt := range tests {
+		if got, err := useColor(tt.mode, file); got != tt.want || err != nil {
+			t.Errorf("useColor(%q) = %v, %v, want %v", tt.mode, got, err, tt.want)
+		}
+	}
+	for _, mode := range []string{"", "yes", "Always"} {
+		if _, err := useColor(mode, file); err == nil {
+			t.Errorf("useColor(%q): no error", mode)
+		}
+	}
+}
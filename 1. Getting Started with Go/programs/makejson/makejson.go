@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// subcommands run instead of the prompt when named as the first argument:
+// "convert" converts records between formats, "structgen" writes Go types
+// for sample JSON and "stream" filters large exports element by element
+var subcommands = map[string]func([]string) error{
+	"convert":   convertCommand,
+	"structgen": structgenCommand,
+	"stream":    streamCommand,
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	format := flag.String("format", "json", "output format: json, xml, cbor, msgpack or proto")
+	indent := flag.Int("indent", 0, "indent width; 0 prints compact JSON")
+	sorted := flag.Bool("sorted", false, "sort keys alphabetically instead of in prompt order")
+	escapeHTML := flag.Bool("escape-html", false, "escape <, > and & as \\u003c, \\u003e and \\u0026")
+	noNewline := flag.Bool("no-newline", false, "don't end the output with a newline")
+	color := flag.String("color", "auto", "colorize output: auto, always or never")
+	postURL := flag.String("post", "", "also send the record to this URL")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout for each -post attempt")
+	retries := flag.Int("retries", 3, "retries for -post after the first attempt")
+	country := flag.String("country", "", "country code for phone numbers entered without one, e.g. 1 or 44")
+	serve := flag.String("serve", "", "serve a web form on this address, e.g. localhost:8080, instead of prompting")
+	flag.Parse()
+
+	colorOutput, err := useColor(*color, os.Stdout)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	if *serve != "" {
+		// The form always makes JSON in prompt order
+		var unused []string
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "format" || f.Name == "sorted" {
+				unused = append(unused, "-"+f.Name)
+			}
+		})
+		if len(unused) > 0 {
+			fmt.Println("Error:", strings.Join(unused, " and "), "cannot be used with -serve")
+			os.Exit(1)
+		}
+		opts := EncodeOptions{Indent: *indent, EscapeHTML: *escapeHTML, TrailingNewline: !*noNewline}
+		if err := serveForm(*serve, opts, *country); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	prompter := &contactPrompter{in: bufio.NewReader(os.Stdin), out: os.Stdout, defaultCountry: *country}
+	contact := prompter.Prompt()
+
+	// Keys are kept in the order they were asked for
+	var record any = contact.Record()
+	if *sorted {
+		record = sortedRecord(record)
+	}
+
+	jsonOpts := EncodeOptions{
+		Indent:          *indent,
+		EscapeHTML:      *escapeHTML,
+		TrailingNewline: !*noNewline,
+	}
+	body, err := encodeRecord(record, *format, jsonOpts)
+	if err != nil {
+		fmt.Println("Error encoding record:", err)
+		return
+	}
+
+	// Colors only go to the terminal, never into the posted body
+	if *format == "json" && colorOutput {
+		jsonOpts.Color = true
+		NewJSONEncoder(os.Stdout, jsonOpts).Encode(record)
+	} else {
+		os.Stdout.Write(body)
+	}
+
+	if *postURL != "" {
+		poster := NewPoster(PostOptions{URL: *postURL, Timeout: *timeout, Retries: *retries})
+		res, err := poster.Post(body, contentTypes[*format])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error posting record:", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "POST %s: %d %s after %d attempt(s), idempotency key %s\n",
+			*postURL, res.Status, http.StatusText(res.Status), res.Attempts, res.IdempotencyKey)
+		if len(res.Body) > 0 {
+			fmt.Fprintf(os.Stderr, "Response: %s\n", bytes.TrimSpace(res.Body))
+		}
+		if res.Status >= 300 {
+			os.Exit(1)
+		}
+	}
+}
+
+// encodeRecord returns the record in the given format
+func encodeRecord(record any, format string, jsonOpts EncodeOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	if format == "json" {
+		err := NewJSONEncoder(&buf, jsonOpts).Encode(record)
+		return buf.Bytes(), err
+	}
+	codec, _, err := codecFor(format, "")
+	if err != nil {
+		return nil, err
+	}
+	err = codec.Encode(&buf, record)
+	return buf.Bytes(), err
+}
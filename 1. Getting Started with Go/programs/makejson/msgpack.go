@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// msgpackCodec implements MessagePack for the record types
+type msgpackCodec struct{}
+
+func (msgpackCodec) Encode(w io.Writer, v any) error {
+	v, err := normalize(v)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	if err := encodeMsgpack(bw, v); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// writeMsgpackLen writes a string, array or map length using the fixed form
+// when it fits, otherwise the 8, 16 or 32 bit form
+func writeMsgpackLen(w *bufio.Writer, n int, fixBase byte, fixMax int, codes [3]byte) error {
+	switch {
+	case n <= fixMax:
+		w.WriteByte(fixBase | byte(n))
+	case codes[0] != 0 && n <= math.MaxUint8:
+		w.WriteByte(codes[0])
+		w.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		w.WriteByte(codes[1])
+		binary.Write(w, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		w.WriteByte(codes[2])
+		binary.Write(w, binary.BigEndian, uint32(n))
+	default:
+		return fmt.Errorf("msgpack: length %d is too large", n)
+	}
+	return nil
+}
+
+func encodeMsgpack(w *bufio.Writer, v any) error {
+	switch v := v.(type) {
+	case nil:
+		w.WriteByte(0xc0)
+	case bool:
+		if v {
+			w.WriteByte(0xc3)
+		} else {
+			w.WriteByte(0xc2)
+		}
+	case int64:
+		switch {
+		case v >= 0 && v <= 0x7f:
+			w.WriteByte(byte(v)) // positive fixint
+		case v < 0 && v >= -32:
+			w.WriteByte(byte(v)) // negative fixint
+		case v >= math.MinInt8 && v <= math.MaxInt8:
+			w.WriteByte(0xd0)
+			w.WriteByte(byte(v))
+		case v >= math.MinInt16 && v <= math.MaxInt16:
+			w.WriteByte(0xd1)
+			binary.Write(w, binary.BigEndian, int16(v))
+		case v >= math.MinInt32 && v <= math.MaxInt32:
+			w.WriteByte(0xd2)
+			binary.Write(w, binary.BigEndian, int32(v))
+		default:
+			w.WriteByte(0xd3)
+			binary.Write(w, binary.BigEndian, v)
+		}
+	case float64:
+		w.WriteByte(0xcb)
+		binary.Write(w, binary.BigEndian, math.Float64bits(v))
+	case string:
+		if err := writeMsgpackLen(w, len(v), 0xa0, 31, [3]byte{0xd9, 0xda, 0xdb}); err != nil {
+			return err
+		}
+		w.WriteString(v)
+	case []any:
+		if err := writeMsgpackLen(w, len(v), 0x90, 15, [3]byte{0, 0xdc, 0xdd}); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := encodeMsgpack(w, item); err != nil {
+				return err
+			}
+		}
+	case *OrderedMap:
+		if err := writeMsgpackLen(w, len(v.Keys()), 0x80, 15, [3]byte{0, 0xde, 0xdf}); err != nil {
+			return err
+		}
+		for _, k := range v.Keys() {
+			value, _ := v.Get(k)
+			if err := encodeMsgpack(w, k); err != nil {
+				return err
+			}
+			if err := encodeMsgpack(w, value); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: cannot encode %T", v)
+	}
+	return nil
+}
+
+func (msgpackCodec) Decode(r io.Reader) (any, error) {
+	return decodeMsgpack(bufio.NewReader(r))
+}
+
+// readBig reads an n byte big-endian unsigned integer
+func readBig(r *bufio.Reader, n int) (uint64, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range buf {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func decodeMsgpack(r *bufio.Reader) (any, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b >= 0xa0 && b <= 0xbf:
+		return readMsgpackString(r, uint64(b&0x1f))
+	case b >= 0x90 && b <= 0x9f:
+		return readMsgpackArray(r, uint64(b&0x0f))
+	case b >= 0x80 && b <= 0x8f:
+		return readMsgpackMap(r, uint64(b&0x0f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf: // uint 8, 16, 32, 64
+		v, err := readBig(r, 1<<(b-0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if v > math.MaxInt64 {
+			return float64(v), nil
+		}
+		return int64(v), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3: // int 8, 16, 32, 64
+		size := 1 << (b - 0xd0)
+		v, err := readBig(r, size)
+		if err != nil {
+			return nil, err
+		}
+		// Sign-extend from the encoded width
+		shift := 64 - 8*size
+		return int64(v<<shift) >> shift, nil
+	case 0xca:
+		v, err := readBig(r, 4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := readBig(r, 8)
+		return math.Float64frombits(v), err
+	case 0xd9, 0xda, 0xdb: // str 8, 16, 32
+		n, err := readBig(r, 1<<(b-0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackString(r, n)
+	case 0xc4, 0xc5, 0xc6: // bin 8, 16, 32, read as text like CBOR byte strings
+		n, err := readBig(r, 1<<(b-0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackString(r, n)
+	case 0xdc, 0xdd:
+		n, err := readBig(r, 2<<(b-0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackArray(r, n)
+	case 0xde, 0xdf:
+		n, err := readBig(r, 2<<(b-0xde))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackMap(r, n)
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type byte 0x%02x", b)
+}
+
+func readMsgpackString(r *bufio.Reader, n uint64) (any, error) {
+	if n > 1<<30 {
+		return nil, fmt.Errorf("msgpack: string of %d bytes is too long", n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func readMsgpackArray(r *bufio.Reader, n uint64) (any, error) {
+	list := []any{}
+	for i := uint64(0); i < n; i++ {
+		item, err := decodeMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+func readMsgpackMap(r *bufio.Reader, n uint64) (any, error) {
+	m := NewOrderedMap()
+	for i := uint64(0); i < n; i++ {
+		key, err := decodeMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		value, err := decodeMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		m.Set(fmt.Sprint(key), value)
+	}
+	return m, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// contentTypes maps each format to the Content-Type sent with it
+var contentTypes = map[string]string{
+	"json":    "application/json",
+	"xml":     "application/xml",
+	"cbor":    "application/cbor",
+	"msgpack": "application/vnd.msgpack",
+	"proto":   "application/x-protobuf",
+}
+
+// PostOptions controls how records are sent to an endpoint
+type PostOptions struct {
+	URL       string
+	Timeout   time.Duration // per attempt
+	Retries   int           // attempts after the first one
+	BaseDelay time.Duration // delay before the first retry, doubled each time
+	MaxDelay  time.Duration // upper bound for a single delay
+}
+
+// PostResult describes the final response
+type PostResult struct {
+	Status         int
+	Attempts       int
+	IdempotencyKey string
+	Body           []byte // start of the response body, for reporting
+}
+
+// Poster sends records with retries. Every attempt for one record carries
+// the same Idempotency-Key header, so the server can drop duplicates when a
+// response was lost rather than the request.
+type Poster struct {
+	opts   PostOptions
+	client *http.Client
+	sleep  func(time.Duration) // replaceable so tests don't have to wait
+	rng    *mathrand.Rand
+}
+
+// NewPoster returns a Poster with defaults filled in
+func NewPoster(opts PostOptions) *Poster {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = 200 * time.Millisecond
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = 10 * time.Second
+	}
+	return &Poster{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		sleep:  time.Sleep,
+		rng:    mathrand.New(mathrand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// newIdempotencyKey returns 16 random bytes in hex
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Post sends body, retrying network errors and 408, 429 and 5xx responses
+func (p *Poster) Post(body []byte, contentType string) (*PostResult, error) {
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return nil, err
+	}
+	res := &PostResult{IdempotencyKey: key}
+
+	for attempt := 0; ; attempt++ {
+		res.Attempts = attempt + 1
+		retryAfter, err := p.try(body, contentType, res)
+		if err == nil && !retryableStatus(res.Status) {
+			return res, nil
+		}
+		if attempt >= p.opts.Retries {
+			if err != nil {
+				return res, fmt.Errorf("giving up after %d attempts: %v", res.Attempts, err)
+			}
+			return res, nil
+		}
+
+		delay := p.backoff(attempt)
+		if retryAfter > delay {
+			delay = min(retryAfter, p.opts.MaxDelay)
+		}
+		p.sleep(delay)
+	}
+}
+
+// try makes one request and returns the Retry-After delay, if any
+func (p *Poster) try(body []byte, contentType string, res *PostResult) (time.Duration, error) {
+	req, err := http.NewRequest(http.MethodPost, p.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json, */*")
+	req.Header.Set("User-Agent", "makejson/1.0")
+	req.Header.Set("Idempotency-Key", res.IdempotencyKey)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	res.Status = resp.StatusCode
+	res.Body, _ = io.ReadAll(io.LimitReader(resp.Body, 512))
+	io.Copy(io.Discard, resp.Body) // let the connection be reused
+
+	return parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()), nil
+}
+
+// parseRetryAfter reads a Retry-After header, which is either a number of
+// seconds or an HTTP date, and returns how long to wait from now. Missing,
+// malformed and past values give 0.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// backoff returns a random delay between 0 and BaseDelay*2^attempt, capped
+// at MaxDelay ("full jitter"), so many clients don't retry in lockstep
+func (p *Poster) backoff(attempt int) time.Duration {
+	limit := p.opts.MaxDelay
+	if attempt < 30 {
+		limit = min(p.opts.BaseDelay<<attempt, p.opts.MaxDelay)
+	}
+	return time.Duration(p.rng.Int63n(int64(limit) + 1))
+}
+
+// retryableStatus reports whether a response is worth trying again
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return status >= 500
+}
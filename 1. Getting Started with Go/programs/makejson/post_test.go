@@ -0,0 +1,142 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPoster returns a Poster for url that records its delays instead of
+// sleeping
+func testPoster(url string, opts PostOptions) (*Poster, *[]time.Duration) {
+	opts.URL = url
+	p := NewPoster(opts)
+	var delays []time.Duration
+	p.sleep = func(d time.Duration) { delays = append(delays, d) }
+	return p, &delays
+}
+
+func TestPostRetriesUntilSuccess(t *testing.T) {
+	statuses := []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusCreated}
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		w.WriteHeader(statuses[len(keys)-1])
+	}))
+	defer server.Close()
+
+	p, delays := testPoster(server.URL, PostOptions{Retries: 5})
+	res, err := p.Post([]byte(`{}`), "application/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != http.StatusCreated || res.Attempts != 3 {
+		t.Errorf("status %d after %d attempts, want 201 after 3", res.Status, res.Attempts)
+	}
+	if len(*delays) != 2 {
+		t.Errorf("slept %d times, want 2", len(*delays))
+	}
+	for _, k := range keys {
+		if k == "" || k != res.IdempotencyKey {
+			t.Errorf("attempt sent Idempotency-Key %q, want %q on every attempt", k, res.IdempotencyKey)
+		}
+	}
+}
+
+func TestPostDoesNotRetryClientErrors(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	p, _ := testPoster(server.URL, PostOptions{Retries: 5})
+	res, err := p.Post(nil, "application/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != http.StatusBadRequest || attempts != 1 {
+		t.Errorf("status %d after %d attempts, want 400 after 1", res.Status, attempts)
+	}
+}
+
+func TestPostBackoffIsCapped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	maxDelay := 50 * time.Millisecond
+	p, delays := testPoster(server.URL, PostOptions{Retries: 8, BaseDelay: time.Second, MaxDelay: maxDelay})
+	if _, err := p.Post(nil, "application/json"); err != nil {
+		t.Fatal(err)
+	}
+	if len(*delays) != 8 {
+		t.Fatalf("slept %d times, want 8", len(*delays))
+	}
+	for _, d := range *delays {
+		// Retry-After asks for an hour, which is longer than the cap
+		if d != maxDelay {
+			t.Errorf("delay %v, want the %v cap", d, maxDelay)
+		}
+	}
+
+	for attempt := range 40 {
+		if d := p.backoff(attempt); d < 0 || d > maxDelay {
+			t.Errorf("backoff(%d) = %v, want between 0 and %v", attempt, d, maxDelay)
+		}
+	}
+}
+
+func TestPostGivesUp(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	p, delays := testPoster(server.URL, PostOptions{Retries: 2})
+	res, err := p.Post(nil, "application/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != http.StatusInternalServerError || attempts != 3 || len(*delays) != 2 {
+		t.Errorf("status %d after %d attempts and %d delays, want 500 after 3 and 2",
+			res.Status, attempts, len(*delays))
+	}
+
+	// Network errors are reported once the retries run out
+	server.Close()
+	p, _ = testPoster(server.URL, PostOptions{Retries: 1})
+	if _, err := p.Post(nil, "application/json"); err == nil || !strings.Contains(err.Error(), "giving up after 2 attempts") {
+		t.Errorf("Post to a closed server: err = %v, want giving up after 2 attempts", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"0", 0},
+		{"-5", 0},
+		{"soon", 0},
+		{"Thu, 15 Oct 2026 12:00:30 GMT", 30 * time.Second},
+		{"Thursday, 15-Oct-26 12:01:00 GMT", time.Minute},
+		{"Thu Oct 15 12:00:10 2026", 10 * time.Second},
+		{"Thu, 15 Oct 2026 11:59:00 GMT", 0}, // already past
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
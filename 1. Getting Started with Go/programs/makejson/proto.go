@@ -0,0 +1,242 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Field numbers from contact.proto
+const (
+	fieldName      = 1
+	fieldAddress   = 2 // deprecated single address
+	fieldPhones    = 3
+	fieldEmails    = 4
+	fieldAddresses = 5
+
+	fieldLabel = 1 // in LabeledValue
+	fieldValue = 2
+)
+
+// ContactMessage is the Contact message from contact.proto. Fields this
+// version doesn't know are kept in unknown, in the message and in each
+// LabeledValue, and written back unchanged, so records from a newer sender
+// survive a decode and encode.
+type ContactMessage struct {
+	Name      string
+	Phones    []LabeledValue
+	Emails    []LabeledValue
+	Addresses []LabeledValue
+	unknown   []byte
+}
+
+// appendVarint appends v in base 128, low groups first
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// consumeVarint reads a varint from the start of b and returns it with the
+// number of bytes used
+func consumeVarint(b []byte) (uint64, int, error) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * i)
+		if b[i] < 0x80 {
+			return v, i + 1, nil
+		}
+	}
+	return 0, 0, errors.New("proto: truncated or overlong varint")
+}
+
+// appendString appends a length-delimited string field
+func appendString(b []byte, field int, s string) []byte {
+	b = appendVarint(b, uint64(field)<<3|wireBytes)
+	b = appendVarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// appendLabeled appends a LabeledValue as an embedded message field
+func appendLabeled(b []byte, field int, v LabeledValue) []byte {
+	var inner []byte
+	if v.Label != "" {
+		inner = appendString(inner, fieldLabel, v.Label)
+	}
+	if v.Value != "" {
+		inner = appendString(inner, fieldValue, v.Value)
+	}
+	inner = append(inner, v.protoUnknown...)
+	return appendString(b, field, string(inner))
+}
+
+// Marshal encodes the message. Empty strings are left out, as proto3 does.
+func (m *ContactMessage) Marshal() []byte {
+	var b []byte
+	if m.Name != "" {
+		b = appendString(b, fieldName, m.Name)
+	}
+	for _, v := range m.Phones {
+		b = appendLabeled(b, fieldPhones, v)
+	}
+	for _, v := range m.Emails {
+		b = appendLabeled(b, fieldEmails, v)
+	}
+	for _, v := range m.Addresses {
+		b = appendLabeled(b, fieldAddresses, v)
+	}
+	return append(b, m.unknown...)
+}
+
+// Unmarshal decodes a message, replacing the contents of m
+func (m *ContactMessage) Unmarshal(b []byte) error {
+	*m = ContactMessage{}
+	for len(b) > 0 {
+		key, n, err := consumeVarint(b)
+		if err != nil {
+			return err
+		}
+		field, wireType := key>>3, key&7
+		if field == 0 {
+			return errors.New("proto: field number 0 is not allowed")
+		}
+
+		size, err := fieldSize(b[n:], wireType)
+		if err != nil {
+			return fmt.Errorf("proto: field %d: %v", field, err)
+		}
+		value := b[n : n+size]
+
+		if wireType != wireBytes || field > fieldAddresses {
+			m.unknown = append(m.unknown, b[:n+size]...)
+			b = b[n+size:]
+			continue
+		}
+
+		data := lengthDelimited(value)
+		switch field {
+		case fieldName:
+			m.Name = string(data)
+		case fieldAddress:
+			if len(data) > 0 {
+				m.Addresses = append(m.Addresses, LabeledValue{Label: "home", Value: string(data)})
+			}
+		default:
+			v, err := unmarshalLabeled(data)
+			if err != nil {
+				return fmt.Errorf("proto: field %d: %v", field, err)
+			}
+			switch field {
+			case fieldPhones:
+				m.Phones = append(m.Phones, v)
+			case fieldEmails:
+				m.Emails = append(m.Emails, v)
+			case fieldAddresses:
+				m.Addresses = append(m.Addresses, v)
+			}
+		}
+		b = b[n+size:]
+	}
+	return nil
+}
+
+// unmarshalLabeled decodes an embedded LabeledValue, keeping unknown fields
+func unmarshalLabeled(b []byte) (LabeledValue, error) {
+	var v LabeledValue
+	for len(b) > 0 {
+		key, n, err := consumeVarint(b)
+		if err != nil {
+			return v, err
+		}
+		size, err := fieldSize(b[n:], key&7)
+		if err != nil {
+			return v, err
+		}
+		switch {
+		case key&7 == wireBytes && key>>3 == fieldLabel:
+			v.Label = string(lengthDelimited(b[n : n+size]))
+		case key&7 == wireBytes && key>>3 == fieldValue:
+			v.Value = string(lengthDelimited(b[n : n+size]))
+		default:
+			v.protoUnknown = append(v.protoUnknown, b[:n+size]...)
+		}
+		b = b[n+size:]
+	}
+	return v, nil
+}
+
+// fieldSize returns how many bytes the value of a field takes in b
+func fieldSize(b []byte, wireType uint64) (int, error) {
+	switch wireType {
+	case wireVarint:
+		_, n, err := consumeVarint(b)
+		return n, err
+	case wireFixed64:
+		if len(b) < 8 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		return 8, nil
+	case wireFixed32:
+		if len(b) < 4 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		return 4, nil
+	case wireBytes:
+		length, n, err := consumeVarint(b)
+		if err != nil {
+			return 0, err
+		}
+		if length > uint64(len(b)-n) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		return n + int(length), nil
+	}
+	return 0, fmt.Errorf("unsupported wire type %d", wireType)
+}
+
+// lengthDelimited strips the length prefix from a length-delimited value
+func lengthDelimited(value []byte) []byte {
+	_, n, _ := consumeVarint(value)
+	return value[n:]
+}
+
+// protoCodec lets convert and -format use the protobuf encoding. Only
+// records shaped like a Contact can be written.
+type protoCodec struct{}
+
+func (protoCodec) Encode(w io.Writer, v any) error {
+	v, err := normalize(v)
+	if err != nil {
+		return err
+	}
+	c, err := contactFromRecord(v)
+	if err != nil {
+		return fmt.Errorf("proto: %v", err)
+	}
+	m := ContactMessage{Name: c.Name, Phones: c.Phones, Emails: c.Emails, Addresses: c.Addresses, unknown: c.protoUnknown}
+	_, err = w.Write(m.Marshal())
+	return err
+}
+
+func (protoCodec) Decode(r io.Reader) (any, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var m ContactMessage
+	if err := m.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	c := Contact{Name: m.Name, Phones: m.Phones, Emails: m.Emails, Addresses: m.Addresses, protoUnknown: m.unknown}
+	return c.Record(), nil
+}
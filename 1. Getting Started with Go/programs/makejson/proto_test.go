@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// annHex is {name: "Ann", addresses: [{label: "home", value: "1 Main St"}]}
+const annHex = "0a03416e6e2a110a04686f6d65120931204d61696e205374"
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestContactMessageMarshal(t *testing.T) {
+	m := ContactMessage{Name: "Ann", Addresses: []LabeledValue{{Label: "home", Value: "1 Main St"}}}
+	if got := hex.EncodeToString(m.Marshal()); got != annHex {
+		t.Errorf("Marshal() = %s, want %s", got, annHex)
+	}
+}
+
+func TestContactMessageUnmarshal(t *testing.T) {
+	var m ContactMessage
+	if err := m.Unmarshal(mustHex(t, annHex)); err != nil {
+		t.Fatal(err)
+	}
+	want := ContactMessage{Name: "Ann", Addresses: []LabeledValue{{Label: "home", Value: "1 Main St"}}}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", m, want)
+	}
+}
+
+func TestContactMessageDeprecatedAddress(t *testing.T) {
+	// address = 2 "1 Main St" reads as a home address
+	var m ContactMessage
+	if err := m.Unmarshal(mustHex(t, "1209 31204d61696e205374")); err != nil {
+		t.Fatal(err)
+	}
+	want := []LabeledValue{{Label: "home", Value: "1 Main St"}}
+	if !reflect.DeepEqual(m.Addresses, want) {
+		t.Errorf("Addresses = %+v, want %+v", m.Addresses, want)
+	}
+}
+
+// withUnknown is annHex plus fields a newer version might send: a varint
+// field 3 inside the address, and a string field 9 and a fixed32 field 10
+// at the top level
+const withUnknown = "0a03416e6e" +
+	"2a13" + "0a04686f6d65" + "120931204d61696e205374" + "1801" +
+	"4a026869" + "55deadbeef"
+
+func TestContactMessageKeepsUnknownFields(t *testing.T) {
+	data := mustHex(t, withUnknown)
+	var m ContactMessage
+	if err := m.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "Ann" || len(m.Addresses) != 1 || m.Addresses[0].Value != "1 Main St" {
+		t.Fatalf("known fields lost: %+v", m)
+	}
+	if got := m.Marshal(); !bytes.Equal(got, data) {
+		t.Errorf("Marshal() = %x, want %x", got, data)
+	}
+}
+
+func TestProtoCodecKeepsUnknownFields(t *testing.T) {
+	data := mustHex(t, withUnknown)
+	record, err := protoCodec{}.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// proto -> proto
+	var out bytes.Buffer
+	if err := (protoCodec{}).Encode(&out, record); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Errorf("proto -> proto = %x, want %x", out.Bytes(), data)
+	}
+
+	// proto -> JSON -> proto
+	var js bytes.Buffer
+	if err := (jsonCodec{}).Encode(&js, record); err != nil {
+		t.Fatal(err)
+	}
+	back, err := jsonCodec{}.Decode(&js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := (protoCodec{}).Encode(&out, back); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Errorf("proto -> JSON -> proto = %x, want %x", out.Bytes(), data)
+	}
+}
+
+func TestContactMessageRejectsBadInput(t *testing.T) {
+	for _, s := range []string{
+		"0a05416e6e",   // string longer than the data
+		"00",           // field number 0
+		"0a",           // missing length
+		"2a030a0141ff", // LabeledValue cut short inside
+		"0b",           // wire type 3 (groups) is not supported
+	} {
+		var m ContactMessage
+		if err := m.Unmarshal(mustHex(t, s)); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded, want an error", s)
+		}
+	}
+}
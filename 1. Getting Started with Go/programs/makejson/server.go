@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed web/*.html
+var webFiles embed.FS
+
+var pages = template.Must(template.ParseFS(webFiles, "web/*.html"))
+
+// Limits for the form fields, in characters
+const (
+	maxNameLen    = 200
+	maxAddressLen = 500
+	maxLabelLen   = 50
+	maxFormRows   = 20   // rows of each kind a form may hold
+	maxResults    = 1000 // results kept for download before the oldest go
+)
+
+// Timeouts for the form server, so a slow or stalled client can't hold a
+// connection open
+const (
+	readHeaderTimeout = 5 * time.Second
+	readTimeout       = 30 * time.Second
+	writeTimeout      = 30 * time.Second
+)
+
+// formServer serves the contact form and keeps the JSON it produced so it
+// can be downloaded
+type formServer struct {
+	secret         []byte // signs CSRF tokens
+	jsonOpts       EncodeOptions
+	defaultCountry string // for phone numbers entered without a country code
+
+	mu      sync.Mutex
+	results map[string][]byte
+	order   []string // result IDs, oldest first
+}
+
+// formPage is the data for form.html
+type formPage struct {
+	Token, Name string
+	NameErrors  []string
+
+	Phones, Emails, Addresses []formRow
+	AddressLabels             []string
+	Invalid                   bool // some field has an error
+}
+
+// formRow is one label and value pair of the form, with what was wrong
+// with it
+type formRow struct {
+	Label, Value string
+	Errors       []string
+}
+
+// resultPage is the data for result.html
+type resultPage struct {
+	ID, JSON string
+}
+
+// serveForm starts the web front-end on addr, which must be a loopback
+// address since there is no login
+func serveForm(addr string, jsonOpts EncodeOptions, defaultCountry string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("refusing to listen on %s: only localhost is allowed", addr)
+	}
+
+	s := newFormServer(jsonOpts)
+	s.defaultCountry = defaultCountry
+	fmt.Printf("Open http://%s/ in your browser (Ctrl+C to stop)\n", addr)
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+	}
+	return server.ListenAndServe()
+}
+
+// newFormServer returns a form server with a fresh CSRF secret
+func newFormServer(jsonOpts EncodeOptions) *formServer {
+	return &formServer{secret: randomBytes(32), jsonOpts: jsonOpts, results: make(map[string][]byte)}
+}
+
+// handler routes the form and download pages
+func (s *formServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleForm)
+	mux.HandleFunc("/download/", s.handleDownload)
+	return mux
+}
+
+// randomBytes returns n bytes from crypto/rand
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// csrfToken ties a token to the browser's session cookie
+func (s *formServer) csrfToken(session string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(session))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// session returns the session ID from the cookie, setting a new cookie if
+// the browser has none yet
+func (s *formServer) session(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie("session"); err == nil && c.Value != "" {
+		return c.Value
+	}
+	id := hex.EncodeToString(randomBytes(16))
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session",
+		Value:    id,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return id
+}
+
+func (s *formServer) handleForm(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	session := s.session(w, r)
+	page := formPage{Token: s.csrfToken(session), AddressLabels: addressLabels}
+
+	switch r.Method {
+	case http.MethodGet:
+		page.addBlankRows("")
+		s.render(w, http.StatusOK, "form.html", page)
+		return
+	case http.MethodPost:
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 16<<10)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad form data", http.StatusBadRequest)
+		return
+	}
+	if !hmac.Equal([]byte(r.PostForm.Get("csrf_token")), []byte(page.Token)) {
+		http.Error(w, "invalid or expired form, please reload the page", http.StatusForbidden)
+		return
+	}
+
+	page.Name = strings.TrimSpace(r.PostForm.Get("name"))
+	page.Phones = formRows(r.PostForm, "phone")
+	page.Emails = formRows(r.PostForm, "email")
+	page.Addresses = formRows(r.PostForm, "address")
+
+	// "Add another" buttons send the form back with one more empty row
+	if more := r.PostForm.Get("more"); more != "" {
+		page.addBlankRows(more)
+		s.render(w, http.StatusOK, "form.html", page)
+		return
+	}
+
+	contact, ok := s.validate(&page)
+	if !ok {
+		page.addBlankRows("")
+		s.render(w, http.StatusUnprocessableEntity, "form.html", page)
+		return
+	}
+
+	data, err := NewJSONEncoder(nil, s.jsonOpts).Marshal(contact.Record())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	id := s.store(data)
+	s.render(w, http.StatusOK, "result.html", resultPage{ID: id, JSON: string(data)})
+}
+
+// formRows reads the label and value rows of one kind, which the form sends
+// as repeated kind_label and kind fields. Rows left empty are dropped.
+func formRows(form url.Values, kind string) []formRow {
+	labels, values := form[kind+"_label"], form[kind]
+	var rows []formRow
+	for i := 0; i < len(values) && len(rows) < maxFormRows; i++ {
+		row := formRow{Value: strings.TrimSpace(values[i])}
+		if i < len(labels) {
+			row.Label = strings.TrimSpace(labels[i])
+		}
+		if row.Value != "" {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// addBlankRows makes sure every list ends in an empty row to type into,
+// and adds another to the list named by more
+func (p *formPage) addBlankRows(more string) {
+	for kind, rows := range map[string]*[]formRow{"phone": &p.Phones, "email": &p.Emails, "address": &p.Addresses} {
+		if len(*rows) >= maxFormRows {
+			continue
+		}
+		if len(*rows) == 0 || (*rows)[len(*rows)-1].Value != "" || kind == more {
+			*rows = append(*rows, formRow{})
+		}
+	}
+}
+
+// validate checks every field of the page with the same rules the terminal
+// prompts use, records the errors next to each field and returns the
+// contact when there were none
+func (s *formServer) validate(page *formPage) (Contact, bool) {
+	page.NameErrors = validateField("Name", page.Name, maxNameLen)
+	page.Invalid = len(page.NameErrors) > 0
+	contact := Contact{Name: page.Name}
+
+	check := func(rows []formRow, what string, maxLen int, checkLabel, checkValue func(string) (string, error)) []LabeledValue {
+		var values []LabeledValue
+		for i := range rows {
+			row := &rows[i]
+			row.Errors = validateField(what+" label", row.Label, maxLabelLen)
+			if len(row.Errors) == 0 && checkLabel != nil {
+				if label, err := checkLabel(row.Label); err != nil {
+					row.Errors = append(row.Errors, capitalize(err.Error())+".")
+				} else {
+					row.Label = label
+				}
+			}
+			valueErrs := validateField(what, row.Value, maxLen)
+			var value string
+			if len(valueErrs) == 0 {
+				var err error
+				if value, err = checkValue(row.Value); err != nil {
+					valueErrs = append(valueErrs, capitalize(err.Error())+".")
+				}
+			}
+			row.Errors = append(row.Errors, valueErrs...)
+			if len(row.Errors) > 0 {
+				page.Invalid = true
+				continue
+			}
+			values = append(values, LabeledValue{Label: row.Label, Value: value})
+		}
+		return values
+	}
+	contact.Phones = check(page.Phones, "Phone", maxLabelLen, nil, func(v string) (string, error) {
+		return NormalizePhone(v, s.defaultCountry)
+	})
+	contact.Emails = check(page.Emails, "Email", maxAddressLen, nil, NormalizeEmail)
+	contact.Addresses = check(page.Addresses, "Address", maxAddressLen, NormalizeAddressLabel, func(v string) (string, error) {
+		return v, nil
+	})
+	return contact, !page.Invalid
+}
+
+// capitalize upper-cases the first letter of an error message so it reads
+// as a sentence on the page
+func capitalize(msg string) string {
+	r, size := utf8.DecodeRuneInString(msg)
+	return string(unicode.ToUpper(r)) + msg[size:]
+}
+
+// validateField checks that a field is filled in, not too long and free of
+// control characters
+func validateField(label, value string, maxLen int) []string {
+	var errs []string
+	if value == "" {
+		errs = append(errs, label+" is required.")
+	}
+	if !utf8.ValidString(value) {
+		errs = append(errs, label+" is not valid text.")
+	} else if utf8.RuneCountInString(value) > maxLen {
+		errs = append(errs, fmt.Sprintf("%s must be at most %d characters.", label, maxLen))
+	}
+	if strings.IndexFunc(value, unicode.IsControl) >= 0 {
+		errs = append(errs, label+" must not contain control characters.")
+	}
+	return errs
+}
+
+// store keeps data for download and returns its ID
+func (s *formServer) store(data []byte) string {
+	id := hex.EncodeToString(randomBytes(16))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[id] = data
+	s.order = append(s.order, id)
+	if len(s.order) > maxResults {
+		delete(s.results, s.order[0])
+		s.order = s.order[1:]
+	}
+	return id
+}
+
+func (s *formServer) handleDownload(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/download/")
+	s.mu.Lock()
+	data, ok := s.results[id]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="contact.json"`)
+	w.Write(data)
+}
+
+// render executes a page template and sends it with status and the usual
+// security headers. The page is built first, so a template error can still
+// become a proper 500 response.
+func (s *formServer) render(w http.ResponseWriter, status int, name string, data any) {
+	var buf bytes.Buffer
+	if err := pages.ExecuteTemplate(&buf, name, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'unsafe-inline'")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// postForm submits the contact form with a valid session and CSRF token
+func postForm(s *formServer, form url.Values) *httptest.ResponseRecorder {
+	form.Set("csrf_token", s.csrfToken("test-session"))
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "test-session"})
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func checkSecurityHeaders(t *testing.T, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	want := map[string]string{
+		"Content-Type":            "text/html; charset=utf-8",
+		"X-Frame-Options":         "DENY",
+		"Content-Security-Policy": "default-src 'self'; style-src 'unsafe-inline'",
+	}
+	// Result has the headers as they were sent, not as changed afterwards
+	sent := rec.Result().Header
+	for header, value := range want {
+		if got := sent.Get(header); got != value {
+			t.Errorf("%s = %q, want %q", header, got, value)
+		}
+	}
+}
+
+func TestFormPageHeaders(t *testing.T) {
+	s := newFormServer(EncodeOptions{})
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET / = %d, want 200", rec.Code)
+	}
+	checkSecurityHeaders(t, rec)
+}
+
+func TestFormResultHeaders(t *testing.T) {
+	s := newFormServer(EncodeOptions{})
+	rec := postForm(s, url.Values{"name": {"Ann"}, "address_label": {"home"}, "address": {"1 Main St"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("valid POST = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	checkSecurityHeaders(t, rec)
+	if !strings.Contains(rec.Body.String(), "1 Main St") {
+		t.Errorf("result page does not show the address:\n%s", rec.Body)
+	}
+}
+
+func TestFormValidationErrorHeaders(t *testing.T) {
+	s := newFormServer(EncodeOptions{})
+	rec := postForm(s, url.Values{"name": {"<script>alert(1)</script>"}, "phone_label": {"mobile"}, "phone": {"+12"}})
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid POST = %d, want 422", rec.Code)
+	}
+	checkSecurityHeaders(t, rec)
+	body := rec.Body.String()
+	if strings.Contains(body, "<script>alert(1)</script>") {
+		t.Errorf("error page echoes the name without escaping:\n%s", body)
+	}
+	if !strings.Contains(body, "must have between 7 and 15 digits") {
+		t.Errorf("error page does not list the error:\n%s", body)
+	}
+}
+
+func TestFormRejectsBadToken(t *testing.T) {
+	s := newFormServer(EncodeOptions{})
+	form := url.Values{"csrf_token": {"forged"}, "name": {"Ann"}, "address": {"1 Main St"}}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "test-session"})
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("POST with a forged token = %d, want 403", rec.Code)
+	}
+}
+
+func TestFormMultipleValues(t *testing.T) {
+	s := newFormServer(EncodeOptions{})
+	s.defaultCountry = "44"
+	rec := postForm(s, url.Values{
+		"name":          {"Ann"},
+		"phone_label":   {"mobile", "work", ""},
+		"phone":         {"07700 900123", "+1 (555) 123-4567", ""},
+		"email_label":   {"personal"},
+		"email":         {"Ann <ann@EXAMPLE.com>"},
+		"address_label": {"Work", "home"},
+		"address":       {"2 Office Rd", ""},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("valid POST = %d, want 200:\n%s", rec.Code, rec.Body)
+	}
+	body := html.UnescapeString(rec.Body.String())
+	for _, want := range []string{
+		"+447700900123", "+15551234567", // normalized phones
+		"ann@example.com",       // bare address, domain in lower case
+		`"work"`, "2 Office Rd", // address label in lower case
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("result does not contain %s:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `"home"`) {
+		t.Errorf("empty address row was kept:\n%s", body)
+	}
+}
+
+func TestFormPerFieldErrors(t *testing.T) {
+	s := newFormServer(EncodeOptions{})
+	rec := postForm(s, url.Values{
+		"name":          {"Ann"},
+		"phone_label":   {"mobile", ""},
+		"phone":         {"+1 555 123 4567", "555-1234"},
+		"email_label":   {"work"},
+		"email":         {"not an email"},
+		"address_label": {"holiday"},
+		"address":       {"1 Beach Rd"},
+	})
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid POST = %d, want 422", rec.Code)
+	}
+	body := html.UnescapeString(rec.Body.String())
+	for _, want := range []string{
+		"Phone label is required.",
+		`"555-1234" needs a country code`,
+		`"not an email" is not a valid email address.`,
+		"Address label must be one of home, work, other.",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("error page does not show %q:\n%s", want, body)
+		}
+	}
+	// What was typed is kept so it can be corrected
+	for _, want := range []string{`value="+1 555 123 4567"`, `value="not an email"`, `value="1 Beach Rd"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("error page lost %s", want)
+		}
+	}
+}
+
+func TestFormAddAnotherRow(t *testing.T) {
+	s := newFormServer(EncodeOptions{})
+	rec := postForm(s, url.Values{"name": {""}, "phone_label": {"mobile"}, "phone": {"+15551234567"}, "more": {"phone"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST with more = %d, want 200", rec.Code)
+	}
+	body := rec.Body.String()
+	if n := strings.Count(body, `name="phone"`); n != 2 {
+		t.Errorf("%d phone rows, want the filled one and a new one", n)
+	}
+	if strings.Contains(body, "is required") {
+		t.Errorf("adding a row validated the form:\n%s", body)
+	}
+}
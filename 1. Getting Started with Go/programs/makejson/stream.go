@@ -0,0 +1,543 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// DefaultMaxElement is the largest element a StreamReader will hold in memory
+const DefaultMaxElement = 16 << 20
+
+// ElementError reports an element that could not be read. The stream carries
+// on with the next element after one of these.
+type ElementError struct {
+	Index  int   // position of the element in the stream, from 0
+	Offset int64 // byte offset where the element starts
+	Err    error
+}
+
+func (e *ElementError) Error() string {
+	return fmt.Sprintf("element %d at byte %d: %v", e.Index, e.Offset, e.Err)
+}
+
+func (e *ElementError) Unwrap() error {
+	return e.Err
+}
+
+// StreamReader reads the elements of a top-level JSON array, or the lines of
+// a JSONL file, one at a time. Only the current element is held in memory,
+// so the size of the whole file doesn't matter.
+//
+// Elements are cut out of the input by a small lexer that only tracks
+// strings and open brackets, then decoded on their own. That is what lets a
+// malformed element be skipped: json.Decoder can't go on after an error.
+// After a mismatched bracket the lexer resynchronizes on the next comma or
+// closing bracket outside a string; an array that is never closed ends the
+// stream with an error instead.
+type StreamReader struct {
+	MaxElement int  // elements larger than this are skipped; 0 means DefaultMaxElement
+	JSONL      bool // read lines even when the input starts with "["
+
+	r      *bufio.Reader
+	offset int64 // bytes consumed so far
+	index  int
+	array  bool // reading a JSON array rather than JSONL
+	begun  bool
+	done   bool
+	buf    []byte // the current element, reused between elements
+	bad    error  // set when the lexer already knows the element is malformed
+	endErr error  // returned instead of io.EOF, e.g. for data after the array
+}
+
+// NewStreamReader returns a reader for r. Input that starts with "[" is read
+// as one array unless the first value ends a line and another value follows,
+// which makes it JSONL whose lines are arrays; set JSONL to skip the guess.
+func NewStreamReader(r io.Reader) *StreamReader {
+	return &StreamReader{r: bufio.NewReaderSize(r, 64<<10)}
+}
+
+func (s *StreamReader) readByte() (byte, error) {
+	c, err := s.r.ReadByte()
+	if err == nil {
+		s.offset++
+	}
+	return c, err
+}
+
+// skipSpace consumes whitespace and returns the next byte without consuming it
+func (s *StreamReader) skipSpace() (byte, error) {
+	for {
+		c, err := s.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			s.r.UnreadByte()
+			return c, nil
+		}
+		s.offset++
+	}
+}
+
+// linesFollow looks ahead from a leading "[" for the end of the first value.
+// It reports whether a newline and then another value come after it, as in
+// JSONL. Only what fits in the read buffer is looked at; a first line longer
+// than that is taken to be the start of an array.
+func (s *StreamReader) linesFollow() bool {
+	ahead, _ := s.r.Peek(s.r.Size())
+	depth := 0
+	inString, escaped := false, false
+	for i, c := range ahead {
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			if depth--; depth == 0 {
+				rest := bytes.TrimLeft(ahead[i+1:], " \t\r")
+				return len(rest) > 0 && rest[0] == '\n' && len(bytes.TrimSpace(rest)) > 0
+			}
+		}
+	}
+	return false
+}
+
+// Index returns the position of the element last returned by Next
+func (s *StreamReader) Index() int {
+	return s.index - 1
+}
+
+// Next returns the next element. At the end of the input it returns io.EOF.
+// A bad element gives an *ElementError, and calling Next again moves on to
+// the element after it; any other error ends the stream.
+func (s *StreamReader) Next() (any, error) {
+	if s.done {
+		if s.endErr != nil {
+			return nil, s.endErr
+		}
+		return nil, io.EOF
+	}
+	if !s.begun {
+		s.begun = true
+		c, err := s.skipSpace()
+		if err != nil {
+			s.done = true
+			return nil, err
+		}
+		if c == '[' && !s.JSONL && !s.linesFollow() {
+			s.readByte()
+			s.array = true
+		}
+	}
+
+	var start int64
+	var err error
+	if s.array {
+		start, err = s.nextArrayElement()
+	} else {
+		start, err = s.nextLine()
+	}
+	if err != nil {
+		s.done = true
+		return nil, err
+	}
+
+	index := s.index
+	s.index++
+	if s.bad != nil {
+		return nil, &ElementError{index, start, s.bad}
+	}
+	if len(s.buf) > s.maxElement() {
+		return nil, &ElementError{index, start, fmt.Errorf("element is larger than %d bytes", s.maxElement())}
+	}
+	v, err := decodeElement(s.buf)
+	if err != nil {
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			err = fmt.Errorf("%v (at byte %d)", err, start+syntax.Offset)
+		}
+		return nil, &ElementError{index, start, err}
+	}
+	return v, nil
+}
+
+// Raw returns the text of the element last returned by Next, without the
+// surrounding whitespace. It is only valid until the next call to Next.
+func (s *StreamReader) Raw() []byte {
+	return bytes.TrimSpace(s.buf)
+}
+
+func (s *StreamReader) maxElement() int {
+	if s.MaxElement > 0 {
+		return s.MaxElement
+	}
+	return DefaultMaxElement
+}
+
+// keep adds a byte to the current element, stopping one byte past the limit
+// so an oversized element is noticed without being held in memory
+func (s *StreamReader) keep(c byte) {
+	if len(s.buf) <= s.maxElement() {
+		s.buf = append(s.buf, c)
+	}
+}
+
+// nextArrayElement reads up to the comma or closing bracket that ends the
+// next element and returns the offset where the element starts
+func (s *StreamReader) nextArrayElement() (int64, error) {
+	c, err := s.skipSpace()
+	if err == io.EOF {
+		return 0, io.ErrUnexpectedEOF // the array was never closed
+	}
+	if err != nil {
+		return 0, err
+	}
+	if c == ']' {
+		at := s.offset
+		s.readByte()
+		if s.index == 0 {
+			if err := s.endArray(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF // empty array
+		}
+		// A comma before the closing bracket, as in [1,2,], is reported as
+		// a bad element so it is not passed over silently
+		s.buf = s.buf[:0]
+		s.bad = errors.New("trailing comma before the closing bracket")
+		s.done = true
+		s.endErr = s.endArray()
+		return at, nil
+	}
+
+	start := s.offset
+	s.buf = s.buf[:0]
+	s.bad = nil
+	var open []byte // closing brackets expected, innermost last
+	inString, escaped := false, false
+	for {
+		c, err := s.readByte()
+		if err == io.EOF {
+			return 0, fmt.Errorf("element %d at byte %d: %w (the array is never closed)", s.index, start, io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case s.bad != nil:
+			// Resynchronizing: brackets no longer mean anything until the
+			// next separator
+			if c == ',' {
+				return start, nil
+			}
+			if c == ']' {
+				s.done = true
+				s.endErr = s.endArray()
+				return start, nil
+			}
+		case c == '{':
+			open = append(open, '}')
+		case c == '[':
+			open = append(open, ']')
+		case len(open) > 0 && c == open[len(open)-1]:
+			open = open[:len(open)-1]
+		case c == ']' && len(open) == 0:
+			s.done = true // end of the array
+			s.endErr = s.endArray()
+			return start, nil
+		case c == '}' || c == ']':
+			s.bad = fmt.Errorf("mismatched %q at byte %d", c, s.offset-1)
+		case c == ',' && len(open) == 0:
+			return start, nil
+		}
+		s.keep(c)
+	}
+}
+
+// endArray checks that nothing but whitespace follows the closing bracket
+// of the array, so data after a bracket mistaken for the end is not lost
+// without a word
+func (s *StreamReader) endArray() error {
+	at := s.offset
+	if _, err := s.skipSpace(); err != io.EOF {
+		if err == nil {
+			err = fmt.Errorf("unexpected data after the array at byte %d", at)
+		}
+		return err
+	}
+	return nil
+}
+
+// nextLine reads the next non-blank line and returns the offset where it starts
+func (s *StreamReader) nextLine() (int64, error) {
+	if _, err := s.skipSpace(); err != nil {
+		return 0, err
+	}
+	start := s.offset
+	s.buf = s.buf[:0]
+	for {
+		c, err := s.readByte()
+		if err == io.EOF || c == '\n' {
+			return start, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		s.keep(c)
+	}
+}
+
+// decodeElement decodes exactly one JSON value from data
+func decodeElement(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeJSONValue(dec)
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the value")
+	}
+	return v, nil
+}
+
+// StreamFunc filters and transforms one element. It returns the value to
+// write, or keep=false to drop the element. An error stops the stream.
+type StreamFunc func(index int, record any) (out any, keep bool, err error)
+
+// StreamStats counts what happened to the elements of a stream
+type StreamStats struct {
+	Read    int // elements decoded
+	Written int // elements fn kept
+	Skipped int // bad elements passed over
+}
+
+// StreamOptions controls StreamRecords
+type StreamOptions struct {
+	Array      bool                                      // write a JSON array instead of JSONL
+	JSONL      bool                                      // see StreamReader.JSONL
+	MaxElement int                                       // see StreamReader.MaxElement
+	Filter     func(index int, record any) (bool, error) // nil keeps every element
+	Func       StreamFunc                                // nil copies elements byte for byte
+	OnError    func(*ElementError)                       // told about each skipped element
+}
+
+// StreamRecords reads every element of r, passes it through opts.Filter and
+// opts.Func and writes the results to w. Bad elements are reported and
+// skipped. Without a Func the elements are copied as they were written, only
+// compacted to one line, so numbers such as 1.0 or 12345678901234567890
+// come out unchanged.
+func StreamRecords(r io.Reader, w io.Writer, opts StreamOptions) (StreamStats, error) {
+	var stats StreamStats
+	asArray, fn := opts.Array, opts.Func
+	var compact bytes.Buffer
+	sr := NewStreamReader(r)
+	sr.MaxElement = opts.MaxElement
+	sr.JSONL = opts.JSONL
+	bw := bufio.NewWriter(w)
+	enc := NewJSONEncoder(bw, EncodeOptions{})
+
+	if asArray {
+		bw.WriteString("[")
+	}
+	for {
+		record, err := sr.Next()
+		if err == io.EOF {
+			break
+		}
+		var elemErr *ElementError
+		if errors.As(err, &elemErr) {
+			stats.Skipped++
+			if opts.OnError != nil {
+				opts.OnError(elemErr)
+			}
+			continue
+		}
+		if err != nil {
+			bw.Flush()
+			return stats, err
+		}
+		stats.Read++
+
+		out, keep := record, true
+		if opts.Filter != nil {
+			if keep, err = opts.Filter(sr.Index(), record); err != nil {
+				bw.Flush()
+				return stats, err
+			}
+		}
+		if keep && fn != nil {
+			if out, keep, err = fn(sr.Index(), record); err != nil {
+				bw.Flush()
+				return stats, err
+			}
+		}
+		if !keep {
+			continue
+		}
+
+		switch {
+		case asArray && stats.Written > 0:
+			bw.WriteString(",\n")
+		case asArray:
+			bw.WriteString("\n")
+		}
+		if fn == nil {
+			compact.Reset()
+			err = json.Compact(&compact, sr.Raw())
+			bw.Write(compact.Bytes())
+		} else {
+			err = enc.Encode(out)
+		}
+		if err != nil {
+			bw.Flush()
+			return stats, err
+		}
+		if !asArray {
+			bw.WriteString("\n")
+		}
+		stats.Written++
+	}
+	if asArray {
+		bw.WriteString("\n]\n")
+	}
+	return stats, bw.Flush()
+}
+
+// fieldMatches reports whether a top-level field of record has the given
+// value, compared as text
+func fieldMatches(record any, key, value string) bool {
+	m, ok := record.(*OrderedMap)
+	if !ok {
+		return false
+	}
+	v, ok := m.Get(key)
+	return ok && fmt.Sprint(v) == value
+}
+
+// selectFields keeps only the named top-level fields of an object, in the
+// order they are named
+func selectFields(record any, keys []string) any {
+	m, ok := record.(*OrderedMap)
+	if !ok {
+		return record
+	}
+	out := NewOrderedMap()
+	for _, k := range keys {
+		if v, ok := m.Get(k); ok {
+			out.Set(k, v)
+		}
+	}
+	return out
+}
+
+// streamCommand implements "stream [-where key=value] [-select a,b] [-array]
+// [-jsonl] [-o out] [in]". It copies a JSON array or JSONL export element by element,
+// keeping those that match and skipping any that are malformed.
+func streamCommand(args []string) error {
+	fs := flag.NewFlagSet("stream", flag.ContinueOnError)
+	where := fs.String("where", "", "keep only elements whose top-level field equals a value, e.g. name=Ann")
+	fields := fs.String("select", "", "comma-separated top-level fields to keep, e.g. name,emails")
+	asArray := fs.Bool("array", false, "write a JSON array instead of JSONL")
+	jsonl := fs.Bool("jsonl", false, "read the input as JSONL even if it starts with \"[\"")
+	maxElement := fs.Int("max-element", DefaultMaxElement, "skip elements larger than this many bytes")
+	output := fs.String("o", "", "output file (default stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: stream [flags] [input]")
+	}
+
+	var whereKey, whereValue string
+	if *where != "" {
+		var ok bool
+		if whereKey, whereValue, ok = strings.Cut(*where, "="); !ok {
+			return fmt.Errorf("-where must look like key=value")
+		}
+	}
+	var keys []string
+	if *fields != "" {
+		keys = strings.Split(*fields, ",")
+	}
+
+	var in io.Reader = os.Stdin
+	if fs.NArg() == 1 {
+		file, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	var out io.Writer = os.Stdout
+	var outFile *os.File
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		out, outFile = file, file
+	}
+
+	opts := StreamOptions{
+		Array:      *asArray,
+		JSONL:      *jsonl,
+		MaxElement: *maxElement,
+		OnError: func(e *ElementError) {
+			fmt.Fprintln(os.Stderr, "Skipped", e)
+		},
+	}
+	if whereKey != "" {
+		opts.Filter = func(index int, record any) (bool, error) {
+			return fieldMatches(record, whereKey, whereValue), nil
+		}
+	}
+	// Without -select elements are copied byte for byte
+	if keys != nil {
+		opts.Func = func(index int, record any) (any, bool, error) {
+			return selectFields(record, keys), true, nil
+		}
+	}
+	stats, err := StreamRecords(in, out, opts)
+	fmt.Fprintf(os.Stderr, "%d read, %d written, %d skipped\n", stats.Read, stats.Written, stats.Skipped)
+	// Closing can report a failed write, so its error counts too
+	if outFile != nil {
+		if cerr := outFile.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
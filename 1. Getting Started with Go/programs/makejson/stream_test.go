@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// stream runs StreamRecords on input and returns the output, the stats, the
+// skipped elements and the final error
+func stream(input string, opts StreamOptions) (string, StreamStats, []*ElementError, error) {
+	var skipped []*ElementError
+	opts.OnError = func(e *ElementError) { skipped = append(skipped, e) }
+	var out bytes.Buffer
+	stats, err := StreamRecords(strings.NewReader(input), &out, opts)
+	return out.String(), stats, skipped, err
+}
+
+func TestStreamResyncsAfterMismatchedBracket(t *testing.T) {
+	out, stats, skipped, err := stream(`[{"a":1},{"a":[2},{"a":3},{"a":4}]`, StreamOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"a\":1}\n{\"a\":3}\n{\"a\":4}\n"; out != want {
+		t.Errorf("output %q, want %q", out, want)
+	}
+	if stats != (StreamStats{Read: 3, Written: 3, Skipped: 1}) {
+		t.Errorf("stats %+v, want 3 read, 3 written, 1 skipped", stats)
+	}
+	if len(skipped) != 1 || skipped[0].Index != 1 || skipped[0].Offset != 9 {
+		t.Errorf("skipped %v, want element 1 at byte 9", skipped)
+	}
+}
+
+func TestStreamReportsTrailingComma(t *testing.T) {
+	_, stats, skipped, err := stream(`[1,2,]`, StreamOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Written != 2 || len(skipped) != 1 || skipped[0].Index != 2 || skipped[0].Offset != 5 {
+		t.Errorf("stats %+v, skipped %v, want element 2 at byte 5", stats, skipped)
+	}
+	if _, _, _, err := stream(`[1,] 2`, StreamOptions{}); err == nil {
+		t.Error("data after [1,]: no error")
+	}
+}
+
+func TestStreamSkipsBadElements(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+		skipped           int
+	}{
+		{"bad value", `[1, tru, 3]`, "1\n3\n", 1},
+		{"empty element", `[1,,3]`, "1\n3\n", 1},
+		{"stray closing brace", `[1, 2}, 3]`, "1\n3\n", 1},
+		{"trailing comma", `[1,2,]`, "1\n2\n", 1},
+		{"trailing comma after space", "[1, 2 ,\n ]", "1\n2\n", 1},
+		{"only commas", `[,]`, "", 2},
+		{"brackets in strings", `["]", "[{", "\"]"]`, "\"]\"\n\"[{\"\n\"\\\"]\"\n", 0},
+		{"bad JSONL line", "{\"a\":1}\n{\"a\":\n{\"a\":3}\n", "{\"a\":1}\n{\"a\":3}\n", 1},
+		{"blank JSONL lines", "\n1\n\n\n2\n", "1\n2\n", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, stats, _, err := stream(tt.input, StreamOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != tt.want || stats.Skipped != tt.skipped {
+				t.Errorf("got %q with %d skipped, want %q with %d", out, stats.Skipped, tt.want, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestStreamDetectsJSONLOfArrays(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+		opts              StreamOptions
+	}{
+		{"array lines", "[1,2]\n[3]\n", "[1,2]\n[3]\n", StreamOptions{}},
+		{"blank line between", "[\"]\"]\r\n\n [{\"a\":[1]}]", "[\"]\"]\n[{\"a\":[1]}]\n", StreamOptions{}},
+		{"mixed lines", "[1]\n{\"a\":2}\n", "[1]\n{\"a\":2}\n", StreamOptions{}},
+		{"array over lines", "[\n[1,2],\n[3]\n]\n", "[1,2]\n[3]\n", StreamOptions{}},
+		{"one array line", "[1,2]\n", "1\n2\n", StreamOptions{}},
+		{"forced", "[1,2]\n", "[1,2]\n", StreamOptions{JSONL: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, stats, _, err := stream(tt.input, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != tt.want || stats.Skipped != 0 {
+				t.Errorf("got %q with %d skipped, want %q", out, stats.Skipped, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamFailsOnBrokenArray(t *testing.T) {
+	for _, input := range []string{
+		`[{"a":1},{"a":[2,{"a":3}`, // never closed
+		`[{"a":1}`,
+		`[{"a":1},{"a":[2}],{"b":1}]`, // "]" ends the array early
+		`[1] 2`,
+	} {
+		if _, _, _, err := stream(input, StreamOptions{}); err == nil {
+			t.Errorf("%s: no error", input)
+		}
+	}
+	_, _, _, err := stream(`[1, 2`, StreamOptions{})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("unclosed array: err = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestStreamCopiesElementsExactly(t *testing.T) {
+	input := "[{\"price\": 1.0, \"id\": 12345678901234567890, \"e\": 1e3},\n  {\"s\": \"caf\\u00e9\"}]"
+	out, _, _, err := stream(input, StreamOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\"price\":1.0,\"id\":12345678901234567890,\"e\":1e3}\n{\"s\":\"caf\\u00e9\"}\n"
+	if out != want {
+		t.Errorf("output %q, want %q", out, want)
+	}
+
+	// A filter alone still copies the elements it keeps byte for byte
+	opts := StreamOptions{Filter: func(index int, record any) (bool, error) { return index == 0, nil }}
+	out, _, _, err = stream(input, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"price\":1.0,\"id\":12345678901234567890,\"e\":1e3}\n"; out != want {
+		t.Errorf("filtered output %q, want %q", out, want)
+	}
+}
+
+func TestStreamFilterAndFunc(t *testing.T) {
+	input := `{"name":"Ann","city":"Oslo"}` + "\n" + `{"name":"Bob","city":"Rome"}` + "\n"
+	opts := StreamOptions{
+		Array: true,
+		Filter: func(index int, record any) (bool, error) {
+			return fieldMatches(record, "city", "Rome"), nil
+		},
+		Func: func(index int, record any) (any, bool, error) {
+			return selectFields(record, []string{"name"}), true, nil
+		},
+	}
+	out, stats, _, err := stream(input, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[\n{\"name\":\"Bob\"}\n]\n"; out != want {
+		t.Errorf("output %q, want %q", out, want)
+	}
+	if stats != (StreamStats{Read: 2, Written: 1}) {
+		t.Errorf("stats %+v, want 2 read, 1 written", stats)
+	}
+}
+
+func TestStreamMaxElement(t *testing.T) {
+	input := `[1, "` + strings.Repeat("x", 100) + `", 3]`
+	out, stats, skipped, err := stream(input, StreamOptions{MaxElement: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "1\n3\n" || stats.Skipped != 1 || len(skipped) != 1 || skipped[0].Index != 1 {
+		t.Errorf("got %q with %+v, want the long element skipped", out, stats)
+	}
+}
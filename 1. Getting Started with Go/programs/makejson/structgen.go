@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// jsonShape is what the samples seen so far say about one JSON value
+type jsonShape struct {
+	kinds    map[string]bool // "null", "bool", "int", "float", "string", "object", "array"
+	fields   *OrderedMap     // field name -> *fieldShape, for objects
+	objCount int             // how many objects were merged, to spot optional fields
+	elem     *jsonShape      // for arrays
+}
+
+// fieldShape is one object field and how many objects had it
+type fieldShape struct {
+	shape *jsonShape
+	seen  int
+}
+
+func newShape() *jsonShape {
+	return &jsonShape{kinds: make(map[string]bool), fields: NewOrderedMap()}
+}
+
+// add merges one decoded value into the shape
+func (s *jsonShape) add(v any) {
+	switch v := v.(type) {
+	case nil:
+		s.kinds["null"] = true
+	case bool:
+		s.kinds["bool"] = true
+	case int64:
+		s.kinds["int"] = true
+	case float64:
+		s.kinds["float"] = true
+	case string:
+		s.kinds["string"] = true
+	case []any:
+		s.kinds["array"] = true
+		if s.elem == nil {
+			s.elem = newShape()
+		}
+		for _, item := range v {
+			s.elem.add(item)
+		}
+	case *OrderedMap:
+		s.kinds["object"] = true
+		s.objCount++
+		for _, k := range v.Keys() {
+			value, _ := v.Get(k)
+			f, ok := s.fields.Get(k)
+			if !ok {
+				f = &fieldShape{shape: newShape()}
+				s.fields.Set(k, f)
+			}
+			f.(*fieldShape).seen++
+			f.(*fieldShape).shape.add(value)
+		}
+	}
+}
+
+// objectOnly reports whether every non-null value in the shape was an object
+func (s *jsonShape) objectOnly() bool {
+	n := len(s.kinds)
+	if s.kinds["null"] {
+		n--
+	}
+	return n == 1 && s.kinds["object"]
+}
+
+// structGen turns shapes into Go type declarations
+type structGen struct {
+	decls []string        // generated struct declarations, root first
+	used  map[string]bool // type names already taken
+}
+
+// goType returns the Go type for a shape; name is used for nested structs
+func (g *structGen) goType(s *jsonShape, name string) string {
+	kinds := make(map[string]bool)
+	for k := range s.kinds {
+		if k != "null" {
+			kinds[k] = true
+		}
+	}
+	// Whole numbers and fractions together are all float64
+	if kinds["int"] && kinds["float"] {
+		delete(kinds, "int")
+	}
+
+	if len(kinds) != 1 {
+		return "any" // only nulls, or values of different kinds
+	}
+	nullable := s.kinds["null"]
+	var t string
+	switch {
+	case kinds["bool"]:
+		t = "bool"
+	case kinds["int"]:
+		t = "int64"
+	case kinds["float"]:
+		t = "float64"
+	case kinds["string"]:
+		t = "string"
+	case kinds["array"]:
+		elem := "any"
+		if s.elem != nil && len(s.elem.kinds) > 0 {
+			elem = g.goType(s.elem, singular(name))
+		}
+		return "[]" + elem // a nil slice already stands for null
+	case kinds["object"]:
+		t = g.structType(s, name)
+	}
+	if nullable {
+		return "*" + t
+	}
+	return t
+}
+
+// structType declares a struct for an object shape and returns its name
+func (g *structGen) structType(s *jsonShape, name string) string {
+	typeName := name
+	for i := 2; g.used[typeName]; i++ {
+		typeName = fmt.Sprintf("%s%d", name, i)
+	}
+	g.used[typeName] = true
+
+	// Reserve a slot so the parent struct comes before its children
+	slot := len(g.decls)
+	g.decls = append(g.decls, "")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "type %s struct {\n", typeName)
+	fieldNames := make(map[string]bool)
+	var untagged []string
+	for _, k := range s.fields.Keys() {
+		value, _ := s.fields.Get(k)
+		f := value.(*fieldShape)
+		tag, ok := tagName(k)
+		if !ok {
+			untagged = append(untagged, strconv.Quote(k))
+			continue
+		}
+
+		fieldName := goName(k)
+		for i := 2; fieldNames[fieldName]; i++ {
+			fieldName = fmt.Sprintf("%s%d", goName(k), i)
+		}
+		fieldNames[fieldName] = true
+
+		t := g.goType(f.shape, goName(k))
+		if f.seen < s.objCount {
+			// Missing from some samples. omitempty never leaves out a struct
+			// value, so nested objects become pointers as well.
+			tag += ",omitempty"
+			if f.shape.objectOnly() && !strings.HasPrefix(t, "*") {
+				t = "*" + t
+			}
+		}
+		fmt.Fprintf(&b, "\t%s %s `json:%q`\n", fieldName, t, tag)
+	}
+	if len(untagged) > 0 {
+		fmt.Fprintf(&b, "\n\t// Left out since a struct tag cannot name them; decode into a\n")
+		fmt.Fprintf(&b, "\t// map[string]any to read them: %s\n", strings.Join(untagged, ", "))
+	}
+	b.WriteString("}\n")
+	g.decls[slot] = b.String()
+	return typeName
+}
+
+// tagName returns the json struct tag name for an object key, and false
+// when encoding/json could not match the key through a tag: it splits tags
+// at commas, ignores names with quotes, backslashes or other unusual
+// punctuation, and a backtick would end the tag early. A key of "-" needs
+// the trailing comma, since a bare "-" means "skip this field".
+func tagName(key string) (string, bool) {
+	if key == "-" {
+		return "-,", true
+	}
+	if key == "" {
+		return "", false
+	}
+	for _, r := range key {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", r) {
+			return "", false
+		}
+	}
+	return key, true
+}
+
+// commonInitialisms are written in capitals, as Go style asks
+var commonInitialisms = map[string]bool{
+	"id": true, "url": true, "uri": true, "http": true, "https": true, "json": true,
+	"xml": true, "api": true, "uuid": true, "ip": true, "html": true, "sql": true,
+}
+
+// goName turns a JSON key such as "first_name" or "zipCode" into an exported
+// Go identifier such as FirstName or ZipCode
+func goName(key string) string {
+	var words []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, string(cur))
+			cur = nil
+		}
+	}
+	for i, r := range key {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && i > 0 && len(cur) > 0 && !unicode.IsUpper(cur[len(cur)-1]):
+			flush()
+			cur = append(cur, r)
+		default:
+			cur = append(cur, r)
+		}
+	}
+	flush()
+
+	var b strings.Builder
+	for _, w := range words {
+		lower := strings.ToLower(w)
+		if commonInitialisms[lower] {
+			b.WriteString(strings.ToUpper(w))
+			continue
+		}
+		runes := []rune(w)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	name := b.String()
+	if name == "" {
+		return "Field"
+	}
+	if unicode.IsDigit([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
+
+// singular makes a rough singular of a plural type name, for the elements
+// of an array: Phones -> Phone, Addresses -> Address, Entries -> Entry
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(name, "sses") || strings.HasSuffix(name, "xes") || strings.HasSuffix(name, "ches"):
+		return name[:len(name)-2]
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && len(name) > 1:
+		return name[:len(name)-1]
+	}
+	return name + "Item"
+}
+
+// GenerateStructs infers Go types from sample documents and returns
+// gofmt-formatted source
+func GenerateStructs(samples []any, typeName, pkg string) ([]byte, error) {
+	shape := newShape()
+	for _, s := range samples {
+		shape.add(s)
+	}
+
+	g := &structGen{used: make(map[string]bool)}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Types inferred by makejson structgen from sample JSON.\n\npackage %s\n\n", pkg)
+	if shape.objectOnly() {
+		// The samples are objects, some perhaps null: the struct is the type.
+		// Declaring typeName as a pointer to itself would not compile.
+		g.structType(shape, typeName)
+	} else {
+		// The samples are not all objects, so name whatever type they are
+		g.used[typeName] = true
+		fmt.Fprintf(&b, "type %s %s\n\n", typeName, g.goType(shape, typeName))
+	}
+	for _, d := range g.decls {
+		b.WriteString(d)
+		b.WriteString("\n")
+	}
+	return format.Source(b.Bytes())
+}
+
+// allObjects reports whether every item of a non-empty list is an object
+func allObjects(list []any) bool {
+	for _, item := range list {
+		if _, ok := item.(*OrderedMap); !ok {
+			return false
+		}
+	}
+	return len(list) > 0
+}
+
+// structgenCommand implements "structgen [-type Name] [-package pkg] sample.json..."
+func structgenCommand(args []string) error {
+	fs := flag.NewFlagSet("structgen", flag.ContinueOnError)
+	typeName := fs.String("type", "Contact", "name of the top-level type")
+	pkg := fs.String("package", "main", "package name for the generated file")
+	output := fs.String("o", "", "output file (default stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: structgen [flags] sample.json...")
+	}
+
+	var samples []any
+	for _, name := range fs.Args() {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		v, err := jsonCodec{}.Decode(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		// An export holding a list of records gives one sample per record
+		if list, ok := v.([]any); ok && allObjects(list) {
+			samples = append(samples, list...)
+		} else {
+			samples = append(samples, v)
+		}
+	}
+
+	src, err := GenerateStructs(samples, goName(*typeName), *pkg)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(*output, src, 0644)
+}
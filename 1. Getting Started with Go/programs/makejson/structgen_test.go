@@ -0,0 +1,179 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// decodeSamples decodes each JSON document with the json codec
+func decodeSamples(t *testing.T, docs ...string) []any {
+	t.Helper()
+	var samples []any
+	for _, doc := range docs {
+		v, err := jsonCodec{}.Decode(strings.NewReader(doc))
+		if err != nil {
+			t.Fatalf("%s: %v", doc, err)
+		}
+		samples = append(samples, v)
+	}
+	return samples
+}
+
+// typeCheck parses and type-checks generated source and returns the package
+func typeCheck(t *testing.T, src []byte) *types.Package {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "generated.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, src)
+	}
+	pkg, err := new(types.Config).Check("sample", fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatalf("generated code does not compile: %v\n%s", err, src)
+	}
+	return pkg
+}
+
+// fieldType returns the type of a struct field in pkg as a string
+func fieldType(t *testing.T, pkg *types.Package, typeName, field string) string {
+	t.Helper()
+	obj := pkg.Scope().Lookup(typeName)
+	if obj == nil {
+		t.Fatalf("no type %s", typeName)
+	}
+	st, ok := obj.Type().Underlying().(*types.Struct)
+	if !ok {
+		t.Fatalf("%s is %s, not a struct", typeName, obj.Type().Underlying())
+	}
+	for i := range st.NumFields() {
+		if st.Field(i).Name() == field {
+			return types.TypeString(st.Field(i).Type(), types.RelativeTo(pkg))
+		}
+	}
+	t.Fatalf("%s has no field %s", typeName, field)
+	return ""
+}
+
+func TestGenerateStructs(t *testing.T) {
+	samples := decodeSamples(t,
+		`{"name": "Ann", "age": 30, "score": 1, "phones": [{"label": "home", "number": "+15551234567"}], "contact": {"email": "a@example.com"}}`,
+		`{"name": "Bob", "age": 41, "score": 2.5, "phones": [], "contact": null, "first_name": "Bob"}`,
+	)
+	src, err := GenerateStructs(samples, "Person", "sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := typeCheck(t, src)
+
+	tests := []struct{ typeName, field, want string }{
+		{"Person", "Name", "string"},
+		{"Person", "Age", "int64"},
+		{"Person", "Score", "float64"},
+		{"Person", "Phones", "[]Phone"},
+		{"Person", "Contact", "*Contact"},
+		{"Person", "FirstName", "string"},
+		{"Phone", "Number", "string"},
+		{"Contact", "Email", "string"},
+	}
+	for _, tt := range tests {
+		if got := fieldType(t, pkg, tt.typeName, tt.field); got != tt.want {
+			t.Errorf("%s.%s is %s, want %s", tt.typeName, tt.field, got, tt.want)
+		}
+	}
+	if !strings.Contains(string(src), "`json:\"first_name,omitempty\"`") {
+		t.Errorf("optional field first_name has no omitempty:\n%s", src)
+	}
+}
+
+func TestGenerateStructsNullableRoot(t *testing.T) {
+	// One sample is null: the root stays a struct, not a pointer to itself
+	samples := decodeSamples(t, `{"name": "Ann"}`, `null`)
+	src, err := GenerateStructs(samples, "Contact", "sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := typeCheck(t, src)
+	if got := fieldType(t, pkg, "Contact", "Name"); got != "string" {
+		t.Errorf("Contact.Name is %s, want string", got)
+	}
+	if strings.Contains(string(src), "type Contact *Contact") {
+		t.Errorf("generated a self-referential pointer type:\n%s", src)
+	}
+}
+
+func TestGenerateStructsOptionalObjectIsPointer(t *testing.T) {
+	samples := decodeSamples(t,
+		`{"name": "Ann", "address": {"city": "Oslo"}}`,
+		`{"name": "Bob"}`,
+	)
+	src, err := GenerateStructs(samples, "Contact", "sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := typeCheck(t, src)
+	if got := fieldType(t, pkg, "Contact", "Address"); got != "*Address" {
+		t.Errorf("optional Contact.Address is %s, want *Address so omitempty works", got)
+	}
+}
+
+func TestGenerateStructsNonObjectRoot(t *testing.T) {
+	for _, docs := range [][]string{
+		{`[1, 2, 3]`},
+		{`"text"`, `5`},
+		{`[{"id": 1}, null]`},
+	} {
+		src, err := GenerateStructs(decodeSamples(t, docs...), "Contact", "sample")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pkg := typeCheck(t, src); pkg.Scope().Lookup("Contact") == nil {
+			t.Errorf("%v: no Contact type:\n%s", docs, src)
+		}
+	}
+}
+
+func TestGenerateStructsAwkwardKeys(t *testing.T) {
+	samples := decodeSamples(t, `{"name": "Ann", "-": 1, "a,b": 2, "x`+"`"+`y": 3, "say \"hi\"": 4, "": 5, "e-mail": "a@example.com"}`)
+	src, err := GenerateStructs(samples, "Contact", "sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := typeCheck(t, src)
+
+	st := pkg.Scope().Lookup("Contact").Type().Underlying().(*types.Struct)
+	tags := make(map[string]string)
+	for i := range st.NumFields() {
+		tags[st.Field(i).Name()] = reflect.StructTag(st.Tag(i)).Get("json")
+	}
+	want := map[string]string{"Name": "name", "Field": "-,", "EMail": "e-mail"}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("fields and tags %v, want %v:\n%s", tags, want, src)
+	}
+	for _, key := range []string{`"a,b"`, "\"x`y\"", `"say \"hi\""`, `""`} {
+		if !strings.Contains(string(src), key) {
+			t.Errorf("comment does not mention the left out key %s:\n%s", key, src)
+		}
+	}
+}
+
+func TestGoName(t *testing.T) {
+	tests := map[string]string{
+		"first_name": "FirstName",
+		"zipCode":    "ZipCode",
+		"user_id":    "UserID",
+		"url":        "URL",
+		"2fa":        "X2fa",
+		"":           "Field",
+		"e-mail":     "EMail",
+	}
+	for key, want := range tests {
+		if got := goName(key); got != want {
+			t.Errorf("goName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
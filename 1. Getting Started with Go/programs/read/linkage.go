@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ContactDoc is one contact document as written by makejson.go. Every field
+// is kept so joined output loses nothing, but only "name" is used to match.
+type ContactDoc map[string]any
+
+// name returns the "name" field of the document, or "" if there is none
+func (c ContactDoc) name() string {
+	s, _ := c["name"].(string)
+	return s
+}
+
+// address returns the single "address" field of older documents, or the
+// first entry of the "addresses" list makejson.go writes now
+func (c ContactDoc) address() string {
+	if s, ok := c["address"].(string); ok {
+		return s
+	}
+	if list, ok := c["addresses"].([]any); ok && len(list) > 0 {
+		if first, ok := list[0].(map[string]any); ok {
+			s, _ := first["address"].(string)
+			return s
+		}
+	}
+	return ""
+}
+
+// LinkedRecord is a names file record joined with a contact document
+type LinkedRecord struct {
+	Person     Name
+	Contact    ContactDoc
+	Confidence float64 // 1 for an exact match, lower for fuzzy ones
+}
+
+// LinkResult holds the matches and what was left over on each side
+type LinkResult struct {
+	Linked            []LinkedRecord
+	UnmatchedNames    []Name
+	UnmatchedContacts []ContactDoc
+}
+
+// loadContacts reads a JSON object, a JSON array of objects, or JSONL with
+// one object per line
+func loadContacts(filename string) ([]ContactDoc, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var docs []ContactDoc
+		if err := json.Unmarshal(trimmed, &docs); err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		return docs, nil
+	}
+
+	// A single object is just JSONL with one line
+	var docs []ContactDoc
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	for dec.More() {
+		var doc ContactDoc
+		if err := dec.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// contactKey normalizes a free-form contact name the same way nameKey does
+func contactKey(s string) string {
+	s = Transliterate(s, SchemeBGN, true)
+	// "Doe, John" is the same person as "John Doe"
+	if last, first, ok := strings.Cut(s, ","); ok {
+		s = first + " " + last
+	}
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+// sortedTokens puts the words of a key in order, so word order doesn't matter
+func sortedTokens(key string) string {
+	words := strings.Fields(key)
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// linkScore rates how likely a name and a contact name are the same person
+func linkScore(nameKeyStr, contactKeyStr string) float64 {
+	switch {
+	case nameKeyStr == contactKeyStr:
+		return 1
+	case sortedTokens(nameKeyStr) == sortedTokens(contactKeyStr):
+		return 0.95
+	}
+	return similarity(nameKeyStr, contactKeyStr) * 0.9
+}
+
+// LinkRecords pairs names with contacts one to one, best scores first, and
+// drops pairs scoring below minScore
+func LinkRecords(names []Name, contacts []ContactDoc, minScore float64) LinkResult {
+	type candidate struct {
+		n, c  int
+		score float64
+	}
+
+	contactKeys := make([]string, len(contacts))
+	for j, c := range contacts {
+		contactKeys[j] = contactKey(c.name())
+	}
+
+	var candidates []candidate
+	for i, n := range names {
+		k := nameKey(n)
+		for j, ck := range contactKeys {
+			if ck == "" {
+				continue
+			}
+			if score := linkScore(k, ck); score >= minScore {
+				candidates = append(candidates, candidate{i, j, score})
+			}
+		}
+	}
+	// Ties are broken by input order so the result is always the same
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return candidates[a].score > candidates[b].score
+	})
+
+	var res LinkResult
+	usedName := make([]bool, len(names))
+	usedContact := make([]bool, len(contacts))
+	for _, cand := range candidates {
+		if usedName[cand.n] || usedContact[cand.c] {
+			continue
+		}
+		usedName[cand.n], usedContact[cand.c] = true, true
+		res.Linked = append(res.Linked, LinkedRecord{Person: names[cand.n], Contact: contacts[cand.c], Confidence: cand.score})
+	}
+	for i, n := range names {
+		if !usedName[i] {
+			res.UnmatchedNames = append(res.UnmatchedNames, n)
+		}
+	}
+	for j, c := range contacts {
+		if !usedContact[j] {
+			res.UnmatchedContacts = append(res.UnmatchedContacts, c)
+		}
+	}
+	return res
+}
+
+// linkCommand implements "link [-min 0.8] [-json] names.txt contacts.json"
+func linkCommand(args []string) error {
+	fs := flag.NewFlagSet("link", flag.ContinueOnError)
+	minScore := fs.Float64("min", 0.8, "lowest confidence accepted as a match")
+	asJSON := fs.Bool("json", false, "write joined and unmatched records as JSON lines")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: link [flags] names.txt contacts.json")
+	}
+
+	res, err := linkFiles(fs.Arg(0), fs.Arg(1), *minScore)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		w := bufio.NewWriter(os.Stdout)
+		if err := writeLinkJSON(w, res); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+
+	fmt.Println("Matched:")
+	for _, l := range res.Linked {
+		fmt.Printf("  %-20s %-20s <-> %-30s %3.0f%%  %s\n", l.Person.fname, l.Person.lname,
+			l.Contact.name(), l.Confidence*100, l.Contact.address())
+	}
+	fmt.Println("Names without a contact:")
+	for _, n := range res.UnmatchedNames {
+		fmt.Printf("  %s %s\n", n.fname, n.lname)
+	}
+	fmt.Println("Contacts without a name:")
+	for _, c := range res.UnmatchedContacts {
+		fmt.Printf("  %s\n", c.name())
+	}
+	return nil
+}
+
+// linkFiles loads a names file and a contacts file and links them. Names
+// are loaded whole: cut to 20 characters, a long name could never match its
+// contact exactly.
+func linkFiles(namesFile, contactsFile string, minScore float64) (LinkResult, error) {
+	names, err := loadNames(namesFile, LoadOptions{FullNames: true})
+	if err != nil {
+		return LinkResult{}, err
+	}
+	contacts, err := loadContacts(contactsFile)
+	if err != nil {
+		return LinkResult{}, err
+	}
+	return LinkRecords(names, contacts, minScore), nil
+}
+
+// writeLinkJSON writes one JSON line per record. "match" tells matched
+// records from names without a contact and contacts without a name, so the
+// output covers everything the text report lists.
+func writeLinkJSON(w io.Writer, res LinkResult) error {
+	enc := json.NewEncoder(w)
+	for _, l := range res.Linked {
+		err := enc.Encode(map[string]any{
+			"match":      "linked",
+			"first_name": l.Person.fname,
+			"last_name":  l.Person.lname,
+			"contact":    l.Contact,
+			"confidence": l.Confidence,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, n := range res.UnmatchedNames {
+		err := enc.Encode(map[string]any{
+			"match":      "unmatched_name",
+			"first_name": n.fname,
+			"last_name":  n.lname,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, c := range res.UnmatchedContacts {
+		err := enc.Encode(map[string]any{
+			"match":   "unmatched_contact",
+			"contact": c,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestContactKey(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"John Doe", "john doe"},
+		{"Doe, John", "john doe"},
+		{"  José   GARCÍA ", "jose garcia"},
+		{"Борис Ельцин", "boris eltsin"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := contactKey(tt.in); got != tt.want {
+			t.Errorf("contactKey(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLinkScore(t *testing.T) {
+	tests := []struct {
+		name, contact string
+		lo, hi        float64
+	}{
+		{"ann lee", "ann lee", 1, 1},
+		{"ann lee", "lee ann", 0.95, 0.95},
+		{"katherine johnson", "katharine johnson", 0.84, 0.85}, // 0.9 * (1 - 1/17)
+		{"ann lee", "bob ray", 0, 0.2},
+	}
+	for _, tt := range tests {
+		if got := linkScore(tt.name, tt.contact); got < tt.lo || got > tt.hi {
+			t.Errorf("linkScore(%q, %q) = %.3f, want %.2f to %.2f", tt.name, tt.contact, got, tt.lo, tt.hi)
+		}
+	}
+}
+
+func TestLinkRecords(t *testing.T) {
+	names := []Name{
+		{fname: "Ann", lname: "Lee"},
+		{fname: "Katherine", lname: "Johnson"},
+		{fname: "Bob", lname: "Ray"},
+		{fname: "Eve", lname: "Adams"},
+	}
+	contacts := []ContactDoc{
+		{"name": "Katharine Johnson"},
+		{"name": "Lee, Ann"},
+		{"name": "Ann Lee"}, // a second match for Ann, who is already linked
+		{"phone": "555"},    // no name to match on
+		{"name": "Zed Zulu"},
+	}
+	res := LinkRecords(names, contacts, 0.8)
+
+	var linked []string
+	for _, l := range res.Linked {
+		linked = append(linked, l.Person.fname+"="+l.Contact.name())
+	}
+	// Best scores first, ties in input order
+	if got, want := strings.Join(linked, ", "), "Ann=Lee, Ann, Katherine=Katharine Johnson"; got != want {
+		t.Errorf("linked %s, want %s", got, want)
+	}
+	if res.Linked[0].Confidence != 1 {
+		t.Errorf("exact match confidence %v, want 1", res.Linked[0].Confidence)
+	}
+	if len(res.UnmatchedNames) != 2 || res.UnmatchedNames[0].fname != "Bob" || res.UnmatchedNames[1].fname != "Eve" {
+		t.Errorf("unmatched names %v, want Bob and Eve", res.UnmatchedNames)
+	}
+	if len(res.UnmatchedContacts) != 3 {
+		t.Errorf("unmatched contacts %v, want Ann Lee, the nameless one and Zed Zulu", res.UnmatchedContacts)
+	}
+
+	// A higher threshold drops the fuzzy match
+	res = LinkRecords(names, contacts, 0.9)
+	if len(res.Linked) != 1 || len(res.UnmatchedNames) != 3 {
+		t.Errorf("min 0.9: %d linked and %d unmatched names, want 1 and 3", len(res.Linked), len(res.UnmatchedNames))
+	}
+}
+
+func TestLinkFilesKeepsLongNames(t *testing.T) {
+	dir := t.TempDir()
+	long := "Maximiliana-Wilhelmina Oppenheimer-Rothenberger-Smith"
+	names := writeRoster(t, dir, "names.txt", long+"\n")
+	contacts := writeRoster(t, dir, "contacts.json", `{"name": "`+long+`"}`)
+
+	res, err := linkFiles(names, contacts, 0.8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Linked) != 1 || res.Linked[0].Confidence != 1 {
+		t.Fatalf("got %+v, want one exact match", res)
+	}
+	if p := res.Linked[0].Person; p.fname+" "+p.lname != long {
+		t.Errorf("linked name %s %s, want %s", p.fname, p.lname, long)
+	}
+}
+
+func TestLoadContacts(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, content string
+		want          []string
+	}{
+		{"array", `[{"name": "Ann Lee"}, {"name": "Bob Ray"}]`, []string{"Ann Lee", "Bob Ray"}},
+		{"object", "\n  {\"name\": \"Ann Lee\", \"address\": \"1 Main St\"}\n", []string{"Ann Lee"}},
+		{"jsonl", "{\"name\": \"Ann Lee\"}\n{\"name\": \"Bob Ray\"}\n", []string{"Ann Lee", "Bob Ray"}},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		docs, err := loadContacts(writeRoster(t, dir, tt.name+".json", tt.content))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, d := range docs {
+			got = append(got, d.name())
+		}
+		if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{`[{"name": "Ann"`, `{"name": "Ann"} oops`, `[1, 2]`} {
+		if _, err := loadContacts(writeRoster(t, dir, "bad.json", bad)); err == nil {
+			t.Errorf("%s: no error", bad)
+		}
+	}
+	if _, err := loadContacts(dir + "/missing.json"); err == nil {
+		t.Error("missing file: no error")
+	}
+}
+
+func TestContactAddress(t *testing.T) {
+	tests := []struct {
+		doc  ContactDoc
+		want string
+	}{
+		{ContactDoc{"address": "1 Main St"}, "1 Main St"},
+		{ContactDoc{"addresses": []any{map[string]any{"label": "home", "address": "2 High St"}}}, "2 High St"},
+		{ContactDoc{"addresses": []any{}}, ""},
+		{ContactDoc{}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.doc.address(); got != tt.want {
+			t.Errorf("address of %v = %q, want %q", tt.doc, got, tt.want)
+		}
+	}
+}
+
+func TestWriteLinkJSON(t *testing.T) {
+	res := LinkResult{
+		Linked:            []LinkedRecord{{Person: Name{fname: "Ann", lname: "Lee"}, Contact: ContactDoc{"name": "Ann Lee", "phone": "555"}, Confidence: 0.95}},
+		UnmatchedNames:    []Name{{fname: "Bob", lname: "Ray"}},
+		UnmatchedContacts: []ContactDoc{{"name": "Zed Zulu"}},
+	}
+	var out strings.Builder
+	if err := writeLinkJSON(&out, res); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), out.String())
+	}
+	var records []map[string]any
+	for _, line := range lines {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		records = append(records, rec)
+	}
+	if r := records[0]; r["match"] != "linked" || r["first_name"] != "Ann" || math.Abs(r["confidence"].(float64)-0.95) > 1e-9 ||
+		r["contact"].(map[string]any)["phone"] != "555" {
+		t.Errorf("linked record %v", r)
+	}
+	if r := records[1]; r["match"] != "unmatched_name" || r["last_name"] != "Ray" || r["contact"] != nil {
+		t.Errorf("unmatched name record %v", r)
+	}
+	if r := records[2]; r["match"] != "unmatched_contact" || r["contact"].(map[string]any)["name"] != "Zed Zulu" {
+		t.Errorf("unmatched contact record %v", r)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+)
+
+// weightedName is one entry of a frequency table
+type weightedName struct {
+	name   string
+	weight int
+}
+
+// Frequency tables for common first and last names. The weights are rough
+// relative frequencies, enough to make the output look like a real roster.
+var (
+	firstNameTable = []weightedName{
+		{"James", 330}, {"Mary", 310}, {"John", 320}, {"Patricia", 150}, {"Robert", 310},
+		{"Jennifer", 150}, {"Michael", 300}, {"Linda", 140}, {"William", 230}, {"Elizabeth", 140},
+		{"David", 220}, {"Barbara", 110}, {"Richard", 170}, {"Susan", 110}, {"Joseph", 160},
+		{"Jessica", 100}, {"Thomas", 150}, {"Sarah", 100}, {"Charles", 140}, {"Karen", 100},
+		{"Daniel", 120}, {"Nancy", 90}, {"Matthew", 110}, {"Lisa", 90}, {"Anthony", 100},
+		{"Emily", 80}, {"Mark", 100}, {"Ashley", 80}, {"Steven", 90}, {"Olivia", 70},
+		{"Andrew", 90}, {"Sophia", 70}, {"Kevin", 80}, {"Emma", 70}, {"Brian", 80},
+		{"Alice", 60}, {"Jane", 50}, {"Henry", 50}, {"Grace", 50}, {"Samuel", 50},
+	}
+	lastNameTable = []weightedName{
+		{"Smith", 250}, {"Johnson", 200}, {"Williams", 170}, {"Brown", 150}, {"Jones", 150},
+		{"Garcia", 120}, {"Miller", 120}, {"Davis", 120}, {"Rodriguez", 110}, {"Martinez", 110},
+		{"Hernandez", 100}, {"Lopez", 90}, {"Gonzalez", 90}, {"Wilson", 90}, {"Anderson", 90},
+		{"Thomas", 85}, {"Taylor", 85}, {"Moore", 80}, {"Jackson", 80}, {"Martin", 80},
+		{"Lee", 75}, {"Perez", 70}, {"Thompson", 70}, {"White", 70}, {"Harris", 65},
+		{"Clark", 60}, {"Lewis", 55}, {"Robinson", 55}, {"Walker", 55}, {"Young", 50},
+		{"Doe", 30}, {"Wonderland", 5}, {"Van der Berg", 10}, {"De la Cruz", 10},
+	}
+	// Names outside ASCII, used for the Unicode rate
+	unicodeNameTable = []weightedName{
+		{"José", 30}, {"Zoë", 10}, {"Søren", 10}, {"Łukasz", 10}, {"Müller", 20},
+		{"Ñúñez", 10}, {"Пётр", 10}, {"Чайковский", 10}, {"Юлия", 10}, {"Щербакова", 10},
+		{"Γιώργος", 10}, {"Παπαδόπουλος", 10}, {"李", 5}, {"Nguyễn", 10},
+	}
+)
+
+// GeneratorOptions sets the seed and how often each kind of odd line appears.
+// Rates are probabilities between 0 and 1; 0 turns that kind of line off.
+type GeneratorOptions struct {
+	Seed          int64
+	Lines         int
+	MalformedRate float64 // lines with no space between the names
+	OverlongRate  float64 // names longer than the 20 character limit
+	UnicodeRate   float64 // names with non-ASCII letters
+	DuplicateRate float64 // repeats of an earlier line
+}
+
+// NameGenerator produces a reproducible stream of names file lines
+type NameGenerator struct {
+	opts   GeneratorOptions
+	rng    *rand.Rand
+	first  []weightedName
+	last   []weightedName
+	recent []string // earlier lines, used for duplicates
+}
+
+// NewNameGenerator returns a generator; the same options always give the
+// same lines
+func NewNameGenerator(opts GeneratorOptions) *NameGenerator {
+	return &NameGenerator{
+		opts:  opts,
+		rng:   rand.New(rand.NewSource(opts.Seed)),
+		first: cumulative(firstNameTable),
+		last:  cumulative(lastNameTable),
+	}
+}
+
+// cumulative turns weights into running totals for binary search
+func cumulative(table []weightedName) []weightedName {
+	out := make([]weightedName, len(table))
+	total := 0
+	for i, w := range table {
+		total += w.weight
+		out[i] = weightedName{w.name, total}
+	}
+	return out
+}
+
+// pick draws a name from a cumulative table
+func (g *NameGenerator) pick(table []weightedName) string {
+	n := g.rng.Intn(table[len(table)-1].weight)
+	lo, hi := 0, len(table)-1
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if table[mid].weight > n {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return table[lo].name
+}
+
+// Line returns the next line of the names file, without a newline. Only the
+// first line can draw a duplicate with nothing earlier to repeat; it becomes
+// an ordinary name, so the other rates are never skewed.
+func (g *NameGenerator) Line() string {
+	var line string
+	switch r := g.rng.Float64(); {
+	case r < g.opts.DuplicateRate:
+		if len(g.recent) > 0 {
+			return g.recent[g.rng.Intn(len(g.recent))]
+		}
+		line = g.pick(g.first) + " " + g.pick(g.last)
+	case r < g.opts.DuplicateRate+g.opts.MalformedRate:
+		line = g.malformed()
+	case r < g.opts.DuplicateRate+g.opts.MalformedRate+g.opts.OverlongRate:
+		line = g.overlong()
+	case r < g.opts.DuplicateRate+g.opts.MalformedRate+g.opts.OverlongRate+g.opts.UnicodeRate:
+		line = g.unicodeName()
+	default:
+		line = g.pick(g.first) + " " + g.pick(g.last)
+	}
+
+	// Keep a bounded window of earlier lines to draw duplicates from
+	if len(g.recent) < 1000 {
+		g.recent = append(g.recent, line)
+	} else {
+		g.recent[g.rng.Intn(len(g.recent))] = line
+	}
+	return line
+}
+
+// malformed returns a line the reader should reject. Blank lines are not
+// among them, since names files may use them freely.
+func (g *NameGenerator) malformed() string {
+	switch g.rng.Intn(2) {
+	case 0:
+		return g.pick(g.first)
+	default:
+		return strings.ToLower(g.pick(g.first) + g.pick(g.last))
+	}
+}
+
+// overlong returns a name with at least one part over 20 characters
+func (g *NameGenerator) overlong() string {
+	first := g.pick(g.first)
+	last := g.pick(g.last)
+	for len(last) <= 20 {
+		last += "-" + g.pick(g.last)
+	}
+	if g.rng.Intn(2) == 0 {
+		for len(first) <= 20 {
+			first += g.pick(g.first)
+		}
+	}
+	return first + " " + last
+}
+
+// unicodeName mixes a non-ASCII name with an ordinary one
+func (g *NameGenerator) unicodeName() string {
+	odd := unicodeNameTable[g.rng.Intn(len(unicodeNameTable))].name
+	if g.rng.Intn(2) == 0 {
+		return odd + " " + g.pick(g.last)
+	}
+	return g.pick(g.first) + " " + odd
+}
+
+// WriteTo writes opts.Lines lines to w
+func (g *NameGenerator) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriterSize(w, 64*1024)
+	var written int64
+	for i := 0; i < g.opts.Lines; i++ {
+		n, err := bw.WriteString(g.Line() + "\n")
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, bw.Flush()
+}
+
+// generateCommand implements "generate [flags]", writing a names file
+func generateCommand(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	var opts GeneratorOptions
+	fs.Int64Var(&opts.Seed, "seed", 1, "random seed; the same seed gives the same file")
+	fs.IntVar(&opts.Lines, "n", 1000, "number of lines")
+	fs.Float64Var(&opts.MalformedRate, "malformed", 0.01, "rate of malformed lines")
+	fs.Float64Var(&opts.OverlongRate, "overlong", 0.01, "rate of names over 20 characters")
+	fs.Float64Var(&opts.UnicodeRate, "unicode", 0.05, "rate of non-ASCII names")
+	fs.Float64Var(&opts.DuplicateRate, "dup", 0.02, "rate of duplicated lines")
+	output := fs.String("o", "", "write to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if opts.Lines <= 0 {
+		return fmt.Errorf("line count must be positive")
+	}
+	rates := []struct {
+		flag string
+		rate float64
+	}{
+		{"malformed", opts.MalformedRate}, {"overlong", opts.OverlongRate},
+		{"unicode", opts.UnicodeRate}, {"dup", opts.DuplicateRate},
+	}
+	total := 0.0
+	for _, r := range rates {
+		if r.rate < 0 {
+			return fmt.Errorf("-%s rate must be between 0 and 1", r.flag)
+		}
+		total += r.rate
+	}
+	if total > 1 {
+		return fmt.Errorf("rates must add up to at most 1")
+	}
+
+	gen := NewNameGenerator(opts)
+	if *output == "" {
+		_, err := gen.WriteTo(os.Stdout)
+		return err
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if _, err := gen.WriteTo(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
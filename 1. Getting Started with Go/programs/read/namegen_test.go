@@ -0,0 +1,119 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// lines draws n lines from a fresh generator
+func lines(opts GeneratorOptions, n int) []string {
+	g := NewNameGenerator(opts)
+	out := make([]string, n)
+	for i := range out {
+		out[i] = g.Line()
+	}
+	return out
+}
+
+func TestGeneratorIsReproducible(t *testing.T) {
+	opts := GeneratorOptions{Seed: 42, Lines: 500, MalformedRate: 0.1, OverlongRate: 0.1, UnicodeRate: 0.1, DuplicateRate: 0.1}
+	var a, b strings.Builder
+	if _, err := NewNameGenerator(opts).WriteTo(&a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewNameGenerator(opts).WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if a.String() != b.String() {
+		t.Error("the same seed gave different output")
+	}
+	if n := strings.Count(a.String(), "\n"); n != 500 {
+		t.Errorf("got %d lines, want 500", n)
+	}
+
+	opts.Seed = 43
+	var c strings.Builder
+	NewNameGenerator(opts).WriteTo(&c)
+	if a.String() == c.String() {
+		t.Error("different seeds gave the same output")
+	}
+}
+
+// kind sorts a generated line into the category that produced it
+func kind(line string) string {
+	if !strings.Contains(line, " ") {
+		return "malformed"
+	}
+	// Overlong last names are hyphenated; no name in the tables is, and a
+	// spaced one like Van der Berg may hide the length in its last field
+	if strings.Contains(line, "-") {
+		for _, part := range strings.SplitN(line, " ", 2) {
+			if utf8.RuneCountInString(part) > 20 {
+				return "overlong"
+			}
+		}
+		return "bad overlong"
+	}
+	for _, r := range line {
+		if r >= utf8.RuneSelf {
+			return "unicode"
+		}
+	}
+	return "plain"
+}
+
+func TestGeneratorRates(t *testing.T) {
+	const n = 20000
+	opts := GeneratorOptions{Seed: 7, MalformedRate: 0.1, OverlongRate: 0.05, UnicodeRate: 0.2}
+	counts := map[string]int{}
+	for _, line := range lines(opts, n) {
+		counts[kind(line)]++
+	}
+	if counts["bad overlong"] > 0 {
+		t.Errorf("%d hyphenated lines are not over 20 characters", counts["bad overlong"])
+	}
+	want := map[string]float64{"malformed": 0.1, "overlong": 0.05, "unicode": 0.2, "plain": 0.65}
+	for k, rate := range want {
+		if got := float64(counts[k]) / n; math.Abs(got-rate) > 0.015 {
+			t.Errorf("%s: rate %.3f, want %.2f", k, got, rate)
+		}
+	}
+
+	// With every rate at 0 only plain names come out
+	for _, line := range lines(GeneratorOptions{Seed: 7}, 2000) {
+		if k := kind(line); k != "plain" {
+			t.Fatalf("%q is %s with all rates off", line, k)
+		}
+	}
+}
+
+func TestGeneratorDuplicates(t *testing.T) {
+	// Every line after the first repeats the first
+	all := lines(GeneratorOptions{Seed: 3, DuplicateRate: 1}, 50)
+	for i, line := range all {
+		if line != all[0] {
+			t.Fatalf("line %d is %q, want %q", i, line, all[0])
+		}
+	}
+
+	// Duplicates add to the repeats a plain roster has by chance
+	repeats := func(rate float64) float64 {
+		seen := map[string]bool{}
+		count := 0
+		for _, line := range lines(GeneratorOptions{Seed: 3, DuplicateRate: rate}, 20000) {
+			if seen[line] {
+				count++
+			}
+			seen[line] = true
+		}
+		return float64(count) / 20000
+	}
+	base, dup := repeats(0), repeats(0.3)
+	// The extra repeats are 0.3 of the lines, minus the chance ones they
+	// displace
+	if extra := dup - base; extra < 0.3*(1-base)-0.02 || extra > 0.3+0.02 {
+		t.Errorf("repeat rate went from %.3f to %.3f with -dup 0.3", base, dup)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// checkpoint records how far a names file has been read
+type checkpoint struct {
+	File   string `json:"file"`
+	Offset int64  `json:"offset"` // bytes read, always at the start of a line
+	Lines  int    `json:"lines"`
+	Names  int    `json:"names"`
+	Group  string `json:"group"` // section the next line belongs to
+}
+
+// progressTracker draws a progress bar on stderr and saves checkpoints while
+// a top-level names file is read
+type progressTracker struct {
+	opts  LoadOptions
+	cp    checkpoint
+	total int64 // size of the file
+	show  bool  // stderr is a terminal
+
+	start       time.Time
+	startOffset int64
+	startLines  int
+	lastDraw    time.Time
+	sinceSave   int
+}
+
+// newProgressTracker prepares progress reporting for file. With opts.Resume
+// it loads the checkpoint and moves file to the saved position.
+func newProgressTracker(file *os.File, abs string, opts LoadOptions) (*progressTracker, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	t := &progressTracker{
+		opts:  opts,
+		cp:    checkpoint{File: abs},
+		total: info.Size(),
+		show:  isTerminal(os.Stderr),
+		start: time.Now(),
+	}
+	if t.opts.CheckpointEvery <= 0 {
+		t.opts.CheckpointEvery = 100000
+	}
+
+	if opts.Resume && opts.Checkpoint != "" {
+		data, err := os.ReadFile(opts.Checkpoint)
+		if err != nil {
+			return nil, fmt.Errorf("cannot resume: %v", err)
+		}
+		if err := json.Unmarshal(data, &t.cp); err != nil {
+			return nil, fmt.Errorf("cannot resume: bad checkpoint %s: %v", opts.Checkpoint, err)
+		}
+		if t.cp.File != abs {
+			return nil, fmt.Errorf("cannot resume: checkpoint is for %s", t.cp.File)
+		}
+		if t.cp.Offset > t.total {
+			return nil, fmt.Errorf("cannot resume: %s is shorter than the checkpoint", abs)
+		}
+		if _, err := file.Seek(t.cp.Offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		t.startOffset, t.startLines = t.cp.Offset, t.cp.Lines
+	}
+	return t, nil
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// update is called after every line of the top-level file
+func (t *progressTracker) update(offset int64, lines, names int, group string) error {
+	t.cp.Offset, t.cp.Lines, t.cp.Names, t.cp.Group = offset, lines, names, group
+
+	if t.opts.Checkpoint != "" {
+		t.sinceSave++
+		if t.sinceSave >= t.opts.CheckpointEvery {
+			t.sinceSave = 0
+			if err := t.save(); err != nil {
+				return err
+			}
+		}
+	}
+	if t.show && time.Since(t.lastDraw) >= 200*time.Millisecond {
+		t.draw()
+	}
+	return nil
+}
+
+// save writes the checkpoint to a temporary file first, so a crash while
+// saving never leaves a half-written checkpoint behind
+func (t *progressTracker) save() error {
+	if t.opts.BeforeSave != nil {
+		if err := t.opts.BeforeSave(); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(t.cp)
+	if err != nil {
+		return err
+	}
+	tmp := t.opts.Checkpoint + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.opts.Checkpoint)
+}
+
+// draw prints the progress line: bytes, lines per second and time left
+func (t *progressTracker) draw() {
+	t.lastDraw = time.Now()
+	elapsed := time.Since(t.start).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	linesPerSec := float64(t.cp.Lines-t.startLines) / elapsed
+	bytesPerSec := float64(t.cp.Offset-t.startOffset) / elapsed
+
+	percent := 100.0
+	if t.total > 0 {
+		percent = float64(t.cp.Offset) * 100 / float64(t.total)
+	}
+	eta := "--:--"
+	if bytesPerSec > 0 {
+		left := time.Duration(float64(t.total-t.cp.Offset)/bytesPerSec) * time.Second
+		eta = fmt.Sprintf("%d:%02d", int(left.Minutes()), int(left.Seconds())%60)
+	}
+
+	const width = 30
+	filled := int(percent * width / 100)
+	bar := make([]byte, width)
+	for i := range bar {
+		bar[i] = '-'
+		if i < filled {
+			bar[i] = '#'
+		}
+	}
+	fmt.Fprintf(os.Stderr, "\r[%s] %5.1f%% %s/%s %8.0f lines/s ETA %s ",
+		bar, percent, formatBytes(t.cp.Offset), formatBytes(t.total), linesPerSec, eta)
+}
+
+// finish draws the final progress line and removes the checkpoint, since
+// there is nothing left to resume
+func (t *progressTracker) finish() error {
+	if t.show {
+		t.draw()
+		fmt.Fprintln(os.Stderr)
+	}
+	if t.opts.Checkpoint != "" {
+		if err := os.Remove(t.opts.Checkpoint); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatBytes prints a size as B, KB, MB or GB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	size, suffix := float64(n), ""
+	for _, s := range []string{"KB", "MB", "GB", "TB"} {
+		size /= unit
+		suffix = s
+		if size < unit {
+			break
+		}
+	}
+	return fmt.Sprintf("%.1f%s", size, suffix)
+}
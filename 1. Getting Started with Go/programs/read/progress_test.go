@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var errStop = errors.New("stopped")
+
+// streamUntil reads path with opts and fails after stopAfter names, as if
+// the run had been interrupted; 0 reads the whole file. It returns the
+// names read and the error.
+func streamUntil(path string, opts LoadOptions, stopAfter int) ([]Name, error) {
+	var names []Name
+	err := streamNames(path, opts, func(n Name) error {
+		if stopAfter > 0 && len(names) == stopAfter {
+			return errStop
+		}
+		names = append(names, n)
+		return nil
+	})
+	return names, err
+}
+
+func readCheckpoint(t *testing.T, path string) checkpoint {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		t.Fatal(err)
+	}
+	return cp
+}
+
+func joinNames(names []Name) string {
+	var parts []string
+	for _, n := range names {
+		parts = append(parts, n.group+":"+n.fname+" "+n.lname)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func TestResumeMidFile(t *testing.T) {
+	dir := t.TempDir()
+	// CRLF line endings and a last line without one
+	content := "Ann Lee\r\n[Staff]\r\nBob Ray\r\nCy Dee\r\n# note\r\nDan Fox\r\nEve Adams"
+	path := writeRoster(t, dir, "names.txt", content)
+	cpFile := filepath.Join(dir, "names.checkpoint")
+
+	// Saved after line 2, then stopped while handing over Cy on line 4
+	opts := LoadOptions{Checkpoint: cpFile, CheckpointEvery: 2}
+	if _, err := streamUntil(path, opts, 2); !errors.Is(err, errStop) {
+		t.Fatalf("first run: err = %v, want it stopped", err)
+	}
+	cp := readCheckpoint(t, cpFile)
+	want := checkpoint{File: cp.File, Offset: int64(strings.Index(content, "Bob")), Lines: 2, Names: 1, Group: "Staff"}
+	if cp != want {
+		t.Errorf("checkpoint %+v, want %+v", cp, want)
+	}
+	if abs, _ := filepath.Abs(path); cp.File != abs {
+		t.Errorf("checkpoint is for %s, want %s", cp.File, abs)
+	}
+
+	// Resuming restores the name count: after Bob it is 2, not 1
+	opts = LoadOptions{Checkpoint: cpFile, CheckpointEvery: 1, Resume: true}
+	names, err := streamUntil(path, opts, 1)
+	if !errors.Is(err, errStop) {
+		t.Fatalf("second run: err = %v, want it stopped", err)
+	}
+	if got := joinNames(names); got != "Staff:Bob Ray" {
+		t.Errorf("second run read %s, want Bob Ray in Staff", got)
+	}
+	cp = readCheckpoint(t, cpFile)
+	if cp.Lines != 3 || cp.Names != 2 || cp.Group != "Staff" || cp.Offset != int64(strings.Index(content, "Cy")) {
+		t.Errorf("checkpoint %+v, want 3 lines, 2 names, Staff, offset of Cy", cp)
+	}
+
+	names, err = streamUntil(path, opts, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := joinNames(names), "Staff:Cy Dee, Staff:Dan Fox, Staff:Eve Adams"; got != want {
+		t.Errorf("last run read %s, want %s", got, want)
+	}
+	if _, err := os.Stat(cpFile); !os.IsNotExist(err) {
+		t.Errorf("checkpoint still there after finishing: %v", err)
+	}
+}
+
+func TestCheckpointRemovedOnFinish(t *testing.T) {
+	dir := t.TempDir()
+	path := writeRoster(t, dir, "names.txt", "Ann Lee\nBob Ray\nCy Dee\n")
+	cpFile := filepath.Join(dir, "names.checkpoint")
+
+	saves := 0
+	opts := LoadOptions{Checkpoint: cpFile, CheckpointEvery: 1, BeforeSave: func() error {
+		saves++
+		return nil
+	}}
+	names, err := streamUntil(path, opts, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 3 || saves != 3 {
+		t.Errorf("read %d names with %d saves, want 3 and 3", len(names), saves)
+	}
+	for _, f := range []string{cpFile, cpFile + ".tmp"} {
+		if _, err := os.Stat(f); !os.IsNotExist(err) {
+			t.Errorf("%s still there: %v", filepath.Base(f), err)
+		}
+	}
+}
+
+func TestResumeRejectsBadCheckpoints(t *testing.T) {
+	dir := t.TempDir()
+	a := writeRoster(t, dir, "a.txt", "Ann Lee\nBob Ray\nCy Dee\n")
+	b := writeRoster(t, dir, "b.txt", "Eve Adams\n")
+	cpFile := filepath.Join(dir, "a.checkpoint")
+	if _, err := streamUntil(a, LoadOptions{Checkpoint: cpFile, CheckpointEvery: 1}, 2); !errors.Is(err, errStop) {
+		t.Fatalf("err = %v, want it stopped", err)
+	}
+
+	resume := LoadOptions{Checkpoint: cpFile, Resume: true}
+	if _, err := streamUntil(b, resume, 0); err == nil || !strings.Contains(err.Error(), "checkpoint is for") {
+		t.Errorf("resuming another file: err = %v", err)
+	}
+
+	// The same file, cut shorter than the saved position
+	os.WriteFile(a, []byte("Ann\n"), 0o644)
+	if _, err := streamUntil(a, resume, 0); err == nil || !strings.Contains(err.Error(), "shorter") {
+		t.Errorf("resuming a shorter file: err = %v", err)
+	}
+
+	os.WriteFile(cpFile, []byte("{"), 0o644)
+	if _, err := streamUntil(a, resume, 0); err == nil || !strings.Contains(err.Error(), "bad checkpoint") {
+		t.Errorf("resuming from a broken checkpoint: err = %v", err)
+	}
+	resume.Checkpoint = filepath.Join(dir, "missing")
+	if _, err := streamUntil(a, resume, 0); err == nil {
+		t.Error("resuming without a checkpoint: no error")
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1536, "1.5KB"},
+		{5 << 20, "5.0MB"},
+		{3 << 30, "3.0GB"},
+		{2 << 40, "2.0TB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.n); got != tt.want {
+			t.Errorf("formatBytes(%d) = %s, want %s", tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Define the struct
+type Name struct {
+	fname string
+	lname string
+	group string // section the name was listed under, if any
+
+	// Original spelling, set only when the name was transliterated
+	origFname string
+	origLname string
+}
+
+// LoadOptions controls how a names file is parsed
+type LoadOptions struct {
+	Transliterate bool           // rewrite names in ASCII, keeping the original
+	Scheme        TranslitScheme // romanization used for Cyrillic
+	FullNames     bool           // keep names longer than 20 characters whole
+
+	Checkpoint      string       // file to save the read position in, "" for none
+	CheckpointEvery int          // lines between checkpoints
+	Resume          bool         // continue from Checkpoint instead of the start
+	BeforeSave      func() error // called before each checkpoint, e.g. to flush output
+}
+
+// The program is split over several files, so run it from this directory
+// with "go run *.go" rather than "go run read.go"
+func main() {
+	// Subcommands such as "usernames" take their arguments from the command line
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Prompt user for file name
+	fmt.Print("Enter the name of the text file: ")
+	var filename string
+	fmt.Scan(&filename)
+
+	names, err := loadNames(filename, LoadOptions{})
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return
+	}
+	printNames(names)
+}
+
+// printNames prints all names, with the original spelling if transliterated
+func printNames(names []Name) {
+	fmt.Println("\nNames found in file:")
+	for _, n := range names {
+		printName(os.Stdout, n)
+	}
+}
+
+// printName prints one line of the names listing
+func printName(w io.Writer, n Name) {
+	fmt.Fprintf(w, "First Name: %-20s Last Name: %-20s", n.fname, n.lname)
+	if n.group != "" {
+		fmt.Fprintf(w, " Group: %s", n.group)
+	}
+	if n.origFname != "" || n.origLname != "" {
+		fmt.Fprintf(w, " (%s %s)", n.origFname, n.origLname)
+	}
+	fmt.Fprintln(w)
+}
+
+// runCommand dispatches a subcommand given on the command line
+func runCommand(cmd string, args []string) error {
+	switch cmd {
+	case "list":
+		return listCommand(args)
+	case "usernames":
+		return usernamesCommand(args)
+	case "diff":
+		return diffCommand(args)
+	case "merge":
+		return mergeCommand(args)
+	case "generate":
+		return generateCommand(args)
+	case "sql":
+		return sqlCommand(args)
+	case "link":
+		return linkCommand(args)
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+// listCommand implements "list [-ascii] [-scheme bgn|iso9] [-checkpoint file [-resume]] names.txt"
+//
+// Names are printed as they are read, so huge files never have to fit in
+// memory. With -checkpoint the read position is saved regularly and -resume
+// continues after the last saved position. Lines printed after the last
+// checkpoint of an interrupted run are printed again on resume.
+func listCommand(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	ascii := fs.Bool("ascii", false, "transliterate names to ASCII")
+	scheme := fs.String("scheme", "bgn", "Cyrillic romanization: bgn or iso9")
+	checkpointFile := fs.String("checkpoint", "", "save the read position to this file")
+	every := fs.Int("every", 100000, "lines between checkpoints")
+	resume := fs.Bool("resume", false, "continue from the -checkpoint file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: list [flags] names.txt")
+	}
+
+	if *resume && *checkpointFile == "" {
+		return fmt.Errorf("-resume needs -checkpoint")
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	opts := LoadOptions{
+		Transliterate:   *ascii,
+		Checkpoint:      *checkpointFile,
+		CheckpointEvery: *every,
+		Resume:          *resume,
+		BeforeSave:      out.Flush,
+	}
+	switch *scheme {
+	case "bgn":
+		opts.Scheme = SchemeBGN
+	case "iso9":
+		opts.Scheme = SchemeISO9
+	default:
+		return fmt.Errorf("unknown scheme %q", *scheme)
+	}
+
+	if !*resume {
+		fmt.Fprintln(out, "\nNames found in file:")
+	}
+	err := streamNames(fs.Arg(0), opts, func(n Name) error {
+		printName(out, n)
+		return nil
+	})
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+// loadNames opens a names file and parses it, following @include lines
+func loadNames(filename string, opts LoadOptions) ([]Name, error) {
+	l := &nameLoader{opts: opts}
+	if err := l.loadFile(filename, ""); err != nil {
+		return nil, err
+	}
+	return l.names, nil
+}
+
+// streamNames is like loadNames but hands each name to emit instead of
+// keeping them all in memory, which is what huge files need
+func streamNames(filename string, opts LoadOptions, emit func(Name) error) error {
+	l := &nameLoader{opts: opts, emit: emit}
+	return l.loadFile(filename, "")
+}
+
+// readNames parses a names file from r. Included files are looked up
+// relative to the current directory.
+func readNames(r io.Reader, opts LoadOptions) ([]Name, error) {
+	l := &nameLoader{opts: opts}
+	if err := l.read(r, "<input>", ".", "", nil); err != nil {
+		return nil, err
+	}
+	return l.names, nil
+}
+
+// nameLoader collects names across a file and the files it includes.
+//
+// Besides "first last" lines a names file may contain:
+//
+//	# a comment, ignored like blank lines
+//	[Team A]           records below are in group "Team A"
+//	@include other.txt read other.txt here, relative to this file
+type nameLoader struct {
+	opts  LoadOptions
+	names []Name
+	emit  func(Name) error // when set, names are passed here instead of kept
+	count int              // names found so far
+	open  []string         // files currently being read, for cycle detection
+}
+
+// loadFile reads one file, tagging records with group until a section
+// header in the file says otherwise
+func (l *nameLoader) loadFile(filename, group string) error {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+	for i, f := range l.open {
+		if f == abs {
+			chain := append(append([]string{}, l.open[i:]...), abs)
+			return fmt.Errorf("include cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+
+	// Open the file
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Progress and checkpoints only follow the top-level file
+	var tracker *progressTracker
+	if len(l.open) == 0 {
+		if tracker, err = newProgressTracker(file, abs, l.opts); err != nil {
+			return err
+		}
+		group = tracker.cp.Group
+		l.count = tracker.cp.Names
+	}
+
+	l.open = append(l.open, abs)
+	defer func() { l.open = l.open[:len(l.open)-1] }()
+	if err := l.read(file, filename, filepath.Dir(filename), group, tracker); err != nil {
+		return err
+	}
+	if tracker != nil {
+		return tracker.finish()
+	}
+	return nil
+}
+
+// read parses lines from r; source is only used in messages
+func (l *nameLoader) read(r io.Reader, source, dir, group string, tracker *progressTracker) error {
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	var offset int64
+	if tracker != nil {
+		lineNo, offset = tracker.cp.Lines, tracker.cp.Offset
+	}
+
+	// Count the bytes each line takes, line ending included, so the
+	// checkpoint knows exactly where to continue
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		offset += int64(advance)
+		return advance, token, err
+	})
+
+	// Read each line and parse first and last name
+	for scanner.Scan() {
+		lineNo++
+		if err := l.parseLine(scanner.Text(), source, dir, lineNo, &group); err != nil {
+			return err
+		}
+		if tracker != nil {
+			if err := tracker.update(offset, lineNo, l.count, group); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Check for errors during scanning
+	return scanner.Err()
+}
+
+// parseLine handles one line of a names file; a section header changes group
+func (l *nameLoader) parseLine(line, source, dir string, lineNo int, group *string) error {
+	line = strings.TrimSpace(line)
+
+	switch {
+	case line == "" || strings.HasPrefix(line, "#"):
+		return nil
+	case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+		*group = strings.TrimSpace(line[1 : len(line)-1])
+		return nil
+	case strings.HasPrefix(line, "@include"):
+		path := strings.TrimSpace(strings.TrimPrefix(line, "@include"))
+		if path == "" {
+			return fmt.Errorf("%s:%d: @include needs a file name", source, lineNo)
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if err := l.loadFile(path, *group); err != nil {
+			return fmt.Errorf("%s:%d: %w", source, lineNo, err)
+		}
+		return nil
+	}
+
+	parts := strings.SplitN(line, " ", 2)
+	if len(parts) != 2 {
+		// Warnings go to stderr so exported data on stdout stays clean
+		fmt.Fprintf(os.Stderr, "Skipping malformed line %d of %s: %s\n", lineNo, source, line)
+		return nil
+	}
+
+	person := Name{fname: parts[0], lname: parts[1], group: *group}
+	if l.opts.Transliterate {
+		person = transliterateName(person, l.opts.Scheme)
+	}
+	if !l.opts.FullNames {
+		person.fname = truncate(person.fname, 20)
+		person.lname = truncate(person.lname, 20)
+	}
+	l.count++
+	if l.emit != nil {
+		return l.emit(person)
+	}
+	l.names = append(l.names, person)
+	return nil
+}
+
+// Helper function to truncate string to 20 characters (not bytes, so
+// multi-byte letters are never cut in half)
+func truncate(s string, maxLen int) string {
+	if runes := []rune(s); len(runes) > maxLen {
+		return string(runes[:maxLen])
+	}
+	return s
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeRoster writes a names file into dir and returns its path
+func writeRoster(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestIncludeAndSections(t *testing.T) {
+	dir := t.TempDir()
+	writeRoster(t, dir, "staff.txt", "# staff list\nBob Ray\n\n[Interns]\nCy Dee\n")
+	main := writeRoster(t, dir, "main.txt", "Ann Lee\n[Team A]\n@include staff.txt\nEve Adams\n")
+
+	names, err := loadNames(main, LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, n := range names {
+		got = append(got, n.group+":"+n.fname+" "+n.lname)
+	}
+	// A section started in an included file ends with that file
+	want := ":Ann Lee, Team A:Bob Ray, Interns:Cy Dee, Team A:Eve Adams"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("got %s, want %s", strings.Join(got, ", "), want)
+	}
+}
+
+func TestIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	a := writeRoster(t, dir, "a.txt", "Ann Lee\n@include b.txt\n")
+	writeRoster(t, dir, "b.txt", "Bob Ray\n@include sub/c.txt\n")
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeRoster(t, filepath.Join(dir, "sub"), "c.txt", "@include ../a.txt\n")
+
+	_, err := loadNames(a, LoadOptions{})
+	if err == nil {
+		t.Fatal("no error for an include cycle")
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "include cycle") || !strings.Contains(msg, "a.txt -> ") || strings.Count(msg, "a.txt") < 2 {
+		t.Errorf("error %q does not show the cycle", msg)
+	}
+
+	self := writeRoster(t, dir, "self.txt", "@include self.txt\n")
+	if _, err := loadNames(self, LoadOptions{}); err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("file including itself: %v", err)
+	}
+}
+
+func TestIncludeSameFileTwice(t *testing.T) {
+	dir := t.TempDir()
+	writeRoster(t, dir, "common.txt", "Bob Ray\n")
+	main := writeRoster(t, dir, "main.txt", "@include common.txt\n[B]\n@include common.txt\n")
+	names, err := loadNames(main, LoadOptions{})
+	if err != nil {
+		t.Fatalf("including a file twice is not a cycle: %v", err)
+	}
+	if len(names) != 2 || names[1].group != "B" {
+		t.Errorf("got %+v", names)
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	dir := t.TempDir()
+	for _, content := range []string{"@include\n", "@include missing.txt\n"} {
+		path := writeRoster(t, dir, "bad.txt", content)
+		if _, err := loadNames(path, LoadOptions{}); err == nil || !strings.Contains(err.Error(), "bad.txt:1") {
+			t.Errorf("%q: error %v, want one pointing at bad.txt:1", content, err)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// RosterDiff is the result of comparing two rosters
+type RosterDiff struct {
+	Added   []Name       // only in the new roster
+	Removed []Name       // only in the old roster
+	Changed []NamePair   // matches whose spelling, accents, case, script or section differ
+	Same    []Name       // exact matches
+	order   []diffRecord // every record in roster order, used by merge
+}
+
+// NamePair links a record in the old roster to its match in the new one
+type NamePair struct {
+	Old, New   Name
+	Similarity float64
+}
+
+// diffRecord remembers where a record came from so merge can keep the order
+type diffRecord struct {
+	kind string // "same", "added", "removed" or "changed"
+	name Name
+	pair NamePair
+}
+
+// fuzzyThreshold is the lowest similarity that still counts as the same person
+const fuzzyThreshold = 0.8
+
+// nameKey normalizes a name for matching: transliterated, lowercase and with
+// single spaces
+func nameKey(n Name) string {
+	full := Transliterate(n.fname+" "+n.lname, SchemeBGN, true)
+	return strings.Join(strings.Fields(strings.ToLower(full)), " ")
+}
+
+// rawKey is the name exactly as written
+func rawKey(n Name) string {
+	return n.fname + " " + n.lname
+}
+
+// exactKey is the name exactly as written and its section, for telling
+// unchanged records from respelled or moved ones
+func exactKey(n Name) string {
+	return n.group + "\x00" + rawKey(n)
+}
+
+// DiffRosters matches old and new records, first exactly as written, then
+// by normalized name and then fuzzily by edit distance for whatever is left
+// over. Only the first kind of match counts as unchanged: "Jose Garcia" and
+// "José García" are the same person but a changed record, and so is a name
+// moved to another [section].
+func DiffRosters(oldNames, newNames []Name) RosterDiff {
+	var d RosterDiff
+	usedNew := make([]bool, len(newNames))
+	oldRecords := make([]diffRecord, len(oldNames))
+	leftOld := make([]int, len(oldNames))
+	for i := range leftOld {
+		leftOld[i] = i
+	}
+
+	// matchBy pairs leftover records whose key is equal, allowing for
+	// duplicates on either side, and returns the old records still left
+	matchBy := func(key func(Name) string, match func(i, j int)) []int {
+		unmatched := make(map[string][]int)
+		for j, n := range newNames {
+			if !usedNew[j] {
+				k := key(n)
+				unmatched[k] = append(unmatched[k], j)
+			}
+		}
+		var left []int
+		for _, i := range leftOld {
+			k := key(oldNames[i])
+			if idx := unmatched[k]; len(idx) > 0 {
+				usedNew[idx[0]] = true
+				unmatched[k] = idx[1:]
+				match(i, idx[0])
+			} else {
+				left = append(left, i)
+			}
+		}
+		return left
+	}
+
+	leftOld = matchBy(exactKey, func(i, j int) {
+		d.Same = append(d.Same, oldNames[i])
+		oldRecords[i] = diffRecord{kind: "same", name: oldNames[i]}
+	})
+	leftOld = matchBy(nameKey, func(i, j int) {
+		pair := NamePair{Old: oldNames[i], New: newNames[j], Similarity: similarity(rawKey(oldNames[i]), rawKey(newNames[j]))}
+		d.Changed = append(d.Changed, pair)
+		oldRecords[i] = diffRecord{kind: "changed", pair: pair}
+	})
+
+	// Fuzzy matches: each leftover old record takes its most similar leftover
+	// new record, if that is close enough
+	newKeys := make([]string, len(newNames))
+	for j, n := range newNames {
+		if !usedNew[j] {
+			newKeys[j] = nameKey(n)
+		}
+	}
+	for _, i := range leftOld {
+		oldKey := nameKey(oldNames[i])
+		best, bestSim := -1, 0.0
+		for j := range newNames {
+			if usedNew[j] {
+				continue
+			}
+			if sim := similarity(oldKey, newKeys[j]); sim > bestSim {
+				best, bestSim = j, sim
+			}
+		}
+		if best >= 0 && bestSim >= fuzzyThreshold {
+			usedNew[best] = true
+			pair := NamePair{Old: oldNames[i], New: newNames[best], Similarity: bestSim}
+			d.Changed = append(d.Changed, pair)
+			oldRecords[i] = diffRecord{kind: "changed", pair: pair}
+		} else {
+			d.Removed = append(d.Removed, oldNames[i])
+			oldRecords[i] = diffRecord{kind: "removed", name: oldNames[i]}
+		}
+	}
+
+	d.order = oldRecords
+	for j, n := range newNames {
+		if !usedNew[j] {
+			d.Added = append(d.Added, n)
+			d.order = append(d.order, diffRecord{kind: "added", name: n})
+		}
+	}
+	return d
+}
+
+// similarity turns the edit distance between a and b into a score from 0 to 1
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// levenshtein counts the insertions, deletions and substitutions needed to
+// turn a into b
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// MergePolicy decides which side wins when a record changed
+type MergePolicy string
+
+const (
+	PreferLeft  MergePolicy = "left"
+	PreferRight MergePolicy = "right"
+	KeepBoth    MergePolicy = "both"
+	Interactive MergePolicy = "ask"
+)
+
+// ParseMergePolicy checks that s names one of the merge policies
+func ParseMergePolicy(s string) (MergePolicy, error) {
+	switch p := MergePolicy(s); p {
+	case PreferLeft, PreferRight, KeepBoth, Interactive:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown merge policy %q (use left, right, both or ask)", s)
+}
+
+// MergeRosters returns every record of both rosters once. Changed records are
+// resolved with policy; for Interactive the user is asked on in/out.
+func MergeRosters(d RosterDiff, policy MergePolicy, in io.Reader, out io.Writer) ([]Name, error) {
+	if _, err := ParseMergePolicy(string(policy)); err != nil {
+		return nil, err
+	}
+	var reader *bufio.Reader
+	if policy == Interactive {
+		reader = bufio.NewReader(in)
+	}
+
+	var merged []Name
+	for _, rec := range d.order {
+		if rec.kind != "changed" {
+			merged = append(merged, rec.name)
+			continue
+		}
+
+		choice := policy
+		if policy == Interactive {
+			var err error
+			if choice, err = askConflict(rec.pair, reader, out); err != nil {
+				return nil, err
+			}
+		}
+		switch choice {
+		case PreferLeft:
+			merged = append(merged, rec.pair.Old)
+		case PreferRight:
+			merged = append(merged, rec.pair.New)
+		case KeepBoth:
+			merged = append(merged, rec.pair.Old, rec.pair.New)
+		default:
+			return nil, fmt.Errorf("unknown merge policy %q", policy)
+		}
+	}
+	return merged, nil
+}
+
+// askConflict prompts until the user picks a side for one changed record
+func askConflict(p NamePair, reader *bufio.Reader, out io.Writer) (MergePolicy, error) {
+	for {
+		fmt.Fprintf(out, "Conflict: %s %s <-> %s %s\n", p.Old.fname, p.Old.lname, p.New.fname, p.New.lname)
+		fmt.Fprint(out, "Keep (l)eft, (r)ight or (b)oth? ")
+		answer, err := reader.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "l", "left":
+			return PreferLeft, nil
+		case "r", "right":
+			return PreferRight, nil
+		case "b", "both":
+			return KeepBoth, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("no answer for conflict: %v", err)
+		}
+	}
+}
+
+// writeNames writes records back in the names file format, with a [section]
+// header wherever the group changes. An empty "[]" header ends a section
+// when ungrouped records follow grouped ones.
+func writeNames(w io.Writer, names []Name) error {
+	bw := bufio.NewWriter(w)
+	group := ""
+	for _, n := range names {
+		if n.group != group {
+			fmt.Fprintf(bw, "[%s]\n", n.group)
+			group = n.group
+		}
+		fmt.Fprintf(bw, "%s %s\n", n.fname, n.lname)
+	}
+	return bw.Flush()
+}
+
+// diffCommand implements "diff old.txt new.txt"
+func diffCommand(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: diff old.txt new.txt")
+	}
+	oldNames, newNames, err := loadRosterPair(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	d := DiffRosters(oldNames, newNames)
+	for _, rec := range d.order {
+		switch rec.kind {
+		case "added":
+			fmt.Printf("+ %s %s\n", rec.name.fname, rec.name.lname)
+		case "removed":
+			fmt.Printf("- %s %s\n", rec.name.fname, rec.name.lname)
+		case "changed":
+			fmt.Printf("~ %s -> %s (%.0f%% similar)\n", sectionName(rec.pair.Old, rec.pair.New),
+				sectionName(rec.pair.New, rec.pair.Old), rec.pair.Similarity*100)
+		}
+	}
+	fmt.Printf("%d added, %d removed, %d changed, %d unchanged\n",
+		len(d.Added), len(d.Removed), len(d.Changed), len(d.Same))
+	return nil
+}
+
+// sectionName shows n as "first last", adding its [section] when other is
+// in a different one
+func sectionName(n, other Name) string {
+	if n.group == other.group {
+		return rawKey(n)
+	}
+	return fmt.Sprintf("%s [%s]", rawKey(n), n.group)
+}
+
+// mergeCommand implements "merge [-policy left|right|both|ask] [-o out.txt] left.txt right.txt"
+func mergeCommand(args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	policy := fs.String("policy", "right", "conflict policy: left, right, both or ask")
+	output := fs.String("o", "", "write the merged names file here instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: merge [flags] left.txt right.txt")
+	}
+	mergePolicy, err := ParseMergePolicy(*policy)
+	if err != nil {
+		return err
+	}
+	oldNames, newNames, err := loadRosterPair(fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	// Prompts go to stderr so they don't end up in the merged output
+	merged, err := MergeRosters(DiffRosters(oldNames, newNames), mergePolicy, os.Stdin, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return writeNames(os.Stdout, merged)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := writeNames(file, merged); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// loadRosterPair loads the two names files compared by diff and merge.
+// Names are kept whole, since merge writes them back out.
+func loadRosterPair(left, right string) ([]Name, []Name, error) {
+	oldNames, err := loadNames(left, LoadOptions{FullNames: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	newNames, err := loadNames(right, LoadOptions{FullNames: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	return oldNames, newNames, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMergeKeepsLongNames(t *testing.T) {
+	dir := t.TempDir()
+	long := "Maximiliana-Wilhelmina Oppenheimer-Rothenberger-Smith"
+	left := writeRoster(t, dir, "left.txt", long+"\nAnn Lee\n")
+	right := writeRoster(t, dir, "right.txt", long+"\nBob Ray\n")
+
+	oldNames, newNames, err := loadRosterPair(left, right)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, err := MergeRosters(DiffRosters(oldNames, newNames), PreferRight, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := writeNames(&out, merged); err != nil {
+		t.Fatal(err)
+	}
+	if want := long + "\nAnn Lee\nBob Ray\n"; out.String() != want {
+		t.Errorf("merged roster:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestDiffReportsSectionMove(t *testing.T) {
+	oldNames := []Name{{fname: "Ann", lname: "Lee", group: "staff"}, {fname: "Bob", lname: "Ray", group: "staff"}}
+	newNames := []Name{{fname: "Ann", lname: "Lee", group: "alumni"}, {fname: "Bob", lname: "Ray", group: "staff"}}
+
+	d := DiffRosters(oldNames, newNames)
+	if len(d.Same) != 1 || len(d.Changed) != 1 || len(d.Added) != 0 || len(d.Removed) != 0 {
+		t.Fatalf("got %d same, %d changed, %d added, %d removed, want 1 same and 1 changed",
+			len(d.Same), len(d.Changed), len(d.Added), len(d.Removed))
+	}
+	if p := d.Changed[0]; p.Old.group != "staff" || p.New.group != "alumni" || p.Similarity != 1 {
+		t.Errorf("changed pair %+v, want Ann Lee moved from staff to alumni", p)
+	}
+
+	merged, err := MergeRosters(d, PreferRight, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	writeNames(&out, merged)
+	if want := "[alumni]\nAnn Lee\n[staff]\nBob Ray\n"; out.String() != want {
+		t.Errorf("merged roster:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestDiffRosters(t *testing.T) {
+	oldNames := []Name{
+		{fname: "Ann", lname: "Lee"},
+		{fname: "Jose", lname: "Garcia"},
+		{fname: "Katherine", lname: "Johnson"},
+		{fname: "Bob", lname: "Ray"},
+	}
+	newNames := []Name{
+		{fname: "Ann", lname: "Lee"},
+		{fname: "José", lname: "García"},       // same normalized name
+		{fname: "Katharine", lname: "Johnson"}, // one letter off
+		{fname: "Eve", lname: "Adams"},
+	}
+	d := DiffRosters(oldNames, newNames)
+	if len(d.Same) != 1 || d.Same[0].fname != "Ann" {
+		t.Errorf("same %v, want Ann Lee", d.Same)
+	}
+	if len(d.Changed) != 2 || d.Changed[0].New.fname != "José" || d.Changed[1].New.fname != "Katharine" {
+		t.Errorf("changed %v, want José García and Katharine Johnson", d.Changed)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].fname != "Bob" {
+		t.Errorf("removed %v, want Bob Ray", d.Removed)
+	}
+	if len(d.Added) != 1 || d.Added[0].fname != "Eve" {
+		t.Errorf("added %v, want Eve Adams", d.Added)
+	}
+}
+
+func TestDiffRostersDuplicates(t *testing.T) {
+	ann := Name{fname: "Ann", lname: "Lee"}
+	d := DiffRosters([]Name{ann, ann}, []Name{ann, ann, ann})
+	if len(d.Same) != 2 || len(d.Added) != 1 || len(d.Changed) != 0 {
+		t.Errorf("got %d same, %d added, %d changed, want 2 same and 1 added", len(d.Same), len(d.Added), len(d.Changed))
+	}
+}
+
+func TestMergePolicies(t *testing.T) {
+	oldNames := []Name{{fname: "Ann", lname: "Lee"}, {fname: "Jose", lname: "Garcia"}, {fname: "Bob", lname: "Ray"}}
+	newNames := []Name{{fname: "Ann", lname: "Lee"}, {fname: "José", lname: "García"}, {fname: "Eve", lname: "Adams"}}
+	tests := []struct {
+		policy MergePolicy
+		answer string
+		want   string
+	}{
+		{PreferLeft, "", "Ann Lee, Jose Garcia, Bob Ray, Eve Adams"},
+		{PreferRight, "", "Ann Lee, José García, Bob Ray, Eve Adams"},
+		{KeepBoth, "", "Ann Lee, Jose Garcia, José García, Bob Ray, Eve Adams"},
+		{Interactive, "x\nleft\n", "Ann Lee, Jose Garcia, Bob Ray, Eve Adams"},
+		{Interactive, "R\n", "Ann Lee, José García, Bob Ray, Eve Adams"},
+		{Interactive, "b\n", "Ann Lee, Jose Garcia, José García, Bob Ray, Eve Adams"},
+	}
+	for _, tt := range tests {
+		var prompts strings.Builder
+		merged, err := MergeRosters(DiffRosters(oldNames, newNames), tt.policy, strings.NewReader(tt.answer), &prompts)
+		if err != nil {
+			t.Errorf("%s %q: %v", tt.policy, tt.answer, err)
+			continue
+		}
+		var got []string
+		for _, n := range merged {
+			got = append(got, rawKey(n))
+		}
+		if strings.Join(got, ", ") != tt.want {
+			t.Errorf("%s %q: got %s, want %s", tt.policy, tt.answer, strings.Join(got, ", "), tt.want)
+		}
+		if tt.policy == Interactive && !strings.Contains(prompts.String(), "Conflict: Jose Garcia <-> José García") {
+			t.Errorf("%s: no conflict prompt in %q", tt.policy, prompts.String())
+		}
+	}
+}
+
+func TestMergeInteractiveWithoutAnswer(t *testing.T) {
+	d := DiffRosters([]Name{{fname: "Jose", lname: "Garcia"}}, []Name{{fname: "José", lname: "García"}})
+	if _, err := MergeRosters(d, Interactive, strings.NewReader("maybe\n"), io.Discard); err == nil {
+		t.Error("no error when input ran out before an answer")
+	}
+}
+
+func TestParseMergePolicy(t *testing.T) {
+	for _, s := range []string{"left", "right", "both", "ask"} {
+		if p, err := ParseMergePolicy(s); err != nil || string(p) != s {
+			t.Errorf("ParseMergePolicy(%q) = %q, %v", s, p, err)
+		}
+	}
+	for _, s := range []string{"", "Left", "theirs"} {
+		if _, err := ParseMergePolicy(s); err == nil {
+			t.Errorf("ParseMergePolicy(%q): no error", s)
+		}
+	}
+	if _, err := MergeRosters(RosterDiff{}, MergePolicy("theirs"), nil, nil); err == nil {
+		t.Error("MergeRosters accepted an unknown policy")
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// SQLDialect is a database flavour to write statements for
+type SQLDialect string
+
+const (
+	Postgres SQLDialect = "postgres"
+	MySQL    SQLDialect = "mysql"
+	SQLite   SQLDialect = "sqlite"
+)
+
+// SQLOptions controls the generated script
+type SQLOptions struct {
+	Dialect SQLDialect
+	Table   string
+	Batch   int  // rows per INSERT statement
+	Copy    bool // PostgreSQL COPY ... FROM stdin instead of INSERTs
+}
+
+// sqlColumns are the table columns, in the order the values are written
+var sqlColumns = []string{"id", "first_name", "last_name", "group_name", "original_first_name", "original_last_name"}
+
+// sqlNotNull are the columns declared NOT NULL, where an empty value is
+// written as an empty string rather than NULL
+var sqlNotNull = map[string]bool{"first_name": true, "last_name": true}
+
+// quoteIdent quotes a table or column name
+func quoteIdent(d SQLDialect, name string) string {
+	if d == MySQL {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteString quotes a value as a string literal. An empty value is NULL
+// when the column allows it and an empty literal otherwise.
+func quoteString(d SQLDialect, s string, nullable bool) string {
+	if s == "" && nullable {
+		return "NULL"
+	}
+	s = strings.ReplaceAll(s, "'", "''")
+	if d == MySQL {
+		// MySQL treats backslash as an escape character by default
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return "'" + s + "'"
+}
+
+// createTableSQL returns the CREATE TABLE statement for the names table
+func createTableSQL(opts SQLOptions) string {
+	idType, textType := "INTEGER PRIMARY KEY", "TEXT"
+	if opts.Dialect == MySQL {
+		idType, textType = "INT PRIMARY KEY", "VARCHAR(255)"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE %s (\n", quoteIdent(opts.Dialect, opts.Table))
+	for i, col := range sqlColumns {
+		colType := textType
+		switch {
+		case col == "id":
+			colType = idType
+		case sqlNotNull[col]:
+			colType += " NOT NULL"
+		}
+		fmt.Fprintf(&b, "    %s %s", quoteIdent(opts.Dialect, col), colType)
+		if i < len(sqlColumns)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(");\n")
+	return b.String()
+}
+
+// sqlRow returns the column values of one record
+func sqlRow(id int, n Name) []string {
+	return []string{fmt.Sprint(id), n.fname, n.lname, n.group, n.origFname, n.origLname}
+}
+
+// WriteSQL writes a CREATE TABLE statement followed by the rows of names
+func WriteSQL(w io.Writer, names []Name, opts SQLOptions) error {
+	if opts.Table == "" {
+		opts.Table = "names"
+	}
+	if opts.Batch <= 0 {
+		opts.Batch = 500
+	}
+	switch opts.Dialect {
+	case Postgres, MySQL, SQLite:
+	default:
+		return fmt.Errorf("unknown SQL dialect %q", opts.Dialect)
+	}
+	if opts.Copy && opts.Dialect != Postgres {
+		return fmt.Errorf("COPY output is only supported for %s", Postgres)
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(createTableSQL(opts))
+
+	cols := make([]string, len(sqlColumns))
+	for i, c := range sqlColumns {
+		cols[i] = quoteIdent(opts.Dialect, c)
+	}
+	columnList := strings.Join(cols, ", ")
+
+	if opts.Copy {
+		fmt.Fprintf(bw, "COPY %s (%s) FROM stdin;\n", quoteIdent(opts.Dialect, opts.Table), columnList)
+		for i, n := range names {
+			values := sqlRow(i+1, n)
+			for j, v := range values {
+				values[j] = copyField(v, !sqlNotNull[sqlColumns[j]])
+			}
+			bw.WriteString(strings.Join(values, "\t") + "\n")
+		}
+		bw.WriteString("\\.\n")
+		return bw.Flush()
+	}
+
+	for start := 0; start < len(names); start += opts.Batch {
+		end := min(start+opts.Batch, len(names))
+		fmt.Fprintf(bw, "INSERT INTO %s (%s) VALUES\n", quoteIdent(opts.Dialect, opts.Table), columnList)
+		for i := start; i < end; i++ {
+			values := sqlRow(i+1, names[i])
+			for j, v := range values {
+				if j == 0 {
+					continue // the id is a number
+				}
+				values[j] = quoteString(opts.Dialect, v, !sqlNotNull[sqlColumns[j]])
+			}
+			sep := ","
+			if i == end-1 {
+				sep = ";"
+			}
+			fmt.Fprintf(bw, "    (%s)%s\n", strings.Join(values, ", "), sep)
+		}
+	}
+	return bw.Flush()
+}
+
+// copyField escapes a value for the COPY text format, where \N means NULL
+// and an empty field is an empty string
+func copyField(s string, nullable bool) string {
+	if s == "" && nullable {
+		return `\N`
+	}
+	r := strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+	return r.Replace(s)
+}
+
+// sqlCommand implements "sql [-dialect postgres|mysql|sqlite] [-copy] names.txt"
+func sqlCommand(args []string) error {
+	fs := flag.NewFlagSet("sql", flag.ContinueOnError)
+	var opts SQLOptions
+	dialect := fs.String("dialect", "postgres", "postgres, mysql or sqlite")
+	fs.StringVar(&opts.Table, "table", "names", "table name")
+	fs.IntVar(&opts.Batch, "batch", 500, "rows per INSERT statement")
+	fs.BoolVar(&opts.Copy, "copy", false, "write PostgreSQL COPY data instead of INSERTs")
+	ascii := fs.Bool("ascii", false, "transliterate names to ASCII")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: sql [flags] names.txt")
+	}
+	opts.Dialect = SQLDialect(*dialect)
+
+	names, err := loadNames(fs.Arg(0), LoadOptions{Transliterate: *ascii})
+	if err != nil {
+		return err
+	}
+	return WriteSQL(os.Stdout, names, opts)
+}
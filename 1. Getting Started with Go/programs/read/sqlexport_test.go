@@ -0,0 +1,162 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQuoteString(t *testing.T) {
+	tests := []struct {
+		dialect  SQLDialect
+		in       string
+		nullable bool
+		want     string
+	}{
+		{Postgres, "O'Brien", true, `'O''Brien'`},
+		{SQLite, "it''s", true, `'it''''s'`},
+		{Postgres, `back\slash`, true, `'back\slash'`},
+		{MySQL, `back\slash`, true, `'back\\slash'`},
+		{MySQL, `O'Brien\`, true, `'O''Brien\\'`},
+		{Postgres, "", true, "NULL"},
+		{MySQL, "", false, "''"},
+	}
+	for _, tt := range tests {
+		if got := quoteString(tt.dialect, tt.in, tt.nullable); got != tt.want {
+			t.Errorf("quoteString(%s, %q, %v) = %s, want %s", tt.dialect, tt.in, tt.nullable, got, tt.want)
+		}
+	}
+}
+
+func TestCopyField(t *testing.T) {
+	tests := []struct {
+		in       string
+		nullable bool
+		want     string
+	}{
+		{"Ann", true, "Ann"},
+		{"a\tb", true, `a\tb`},
+		{"line\nbreak\r", true, `line\nbreak\r`},
+		{`back\slash`, true, `back\\slash`},
+		{`\N`, true, `\\N`},
+		{"", true, `\N`},
+		{"", false, ""},
+	}
+	for _, tt := range tests {
+		if got := copyField(tt.in, tt.nullable); got != tt.want {
+			t.Errorf("copyField(%q, %v) = %q, want %q", tt.in, tt.nullable, got, tt.want)
+		}
+	}
+}
+
+var sqlNames = []Name{
+	{fname: "Ann", lname: "O'Brien"},
+	{fname: "Борис", lname: "Ельцин", group: "Staff"},
+	{fname: "", lname: "Lee"}, // e.g. a first name that transliterated to nothing
+}
+
+func TestWriteSQLBatches(t *testing.T) {
+	var out strings.Builder
+	if err := WriteSQL(&out, sqlNames, SQLOptions{Dialect: SQLite, Batch: 2}); err != nil {
+		t.Fatal(err)
+	}
+	want := `CREATE TABLE "names" (
+    "id" INTEGER PRIMARY KEY,
+    "first_name" TEXT NOT NULL,
+    "last_name" TEXT NOT NULL,
+    "group_name" TEXT,
+    "original_first_name" TEXT,
+    "original_last_name" TEXT
+);
+INSERT INTO "names" ("id", "first_name", "last_name", "group_name", "original_first_name", "original_last_name") VALUES
+    (1, 'Ann', 'O''Brien', NULL, NULL, NULL),
+    (2, 'Борис', 'Ельцин', 'Staff', NULL, NULL);
+INSERT INTO "names" ("id", "first_name", "last_name", "group_name", "original_first_name", "original_last_name") VALUES
+    (3, '', 'Lee', NULL, NULL, NULL);
+`
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestWriteSQLBatchBoundaries(t *testing.T) {
+	names := make([]Name, 6)
+	for i := range names {
+		names[i] = Name{fname: "A", lname: "B"}
+	}
+	tests := []struct {
+		rows, batch, statements int
+	}{
+		{0, 2, 0},
+		{1, 2, 1},
+		{4, 2, 2},
+		{5, 2, 3},
+		{6, 6, 1},
+		{6, 0, 1}, // the default batch size
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		if err := WriteSQL(&out, names[:tt.rows], SQLOptions{Dialect: Postgres, Batch: tt.batch}); err != nil {
+			t.Fatal(err)
+		}
+		sql := out.String()
+		if n := strings.Count(sql, "INSERT INTO"); n != tt.statements {
+			t.Errorf("%d rows in batches of %d: %d INSERTs, want %d", tt.rows, tt.batch, n, tt.statements)
+		}
+		// Every statement, including CREATE TABLE, ends with a semicolon
+		if n := strings.Count(sql, ";\n"); n != tt.statements+1 {
+			t.Errorf("%d rows in batches of %d: %d statements ended, want %d", tt.rows, tt.batch, n, tt.statements+1)
+		}
+	}
+}
+
+func TestWriteSQLMySQL(t *testing.T) {
+	var out strings.Builder
+	names := []Name{{fname: `C:\Ann`, lname: "it's", group: "Team `A`"}}
+	if err := WriteSQL(&out, names, SQLOptions{Dialect: MySQL, Table: "my`names"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"CREATE TABLE `my``names` (",
+		"`id` INT PRIMARY KEY,",
+		"`first_name` VARCHAR(255) NOT NULL,",
+		"(1, 'C:\\\\Ann', 'it''s', 'Team `A`', NULL, NULL);",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %s:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestWriteSQLCopy(t *testing.T) {
+	var out strings.Builder
+	names := append(sqlNames, Name{fname: "Tab\there", lname: `Back\slash`, origFname: "Line\nbreak"})
+	if err := WriteSQL(&out, names, SQLOptions{Dialect: Postgres, Copy: true}); err != nil {
+		t.Fatal(err)
+	}
+	_, data, _ := strings.Cut(out.String(), "FROM stdin;\n")
+	want := "1\tAnn\tO'Brien\t\\N\t\\N\t\\N\n" +
+		"2\tБорис\tЕльцин\tStaff\t\\N\t\\N\n" +
+		"3\t\tLee\t\\N\t\\N\t\\N\n" +
+		"4\tTab\\there\tBack\\\\slash\t\\N\tLine\\nbreak\t\\N\n" +
+		"\\.\n"
+	if data != want {
+		t.Errorf("COPY data:\n%q\nwant:\n%q", data, want)
+	}
+}
+
+func TestWriteSQLRejects(t *testing.T) {
+	for _, opts := range []SQLOptions{
+		{Dialect: "oracle"},
+		{Dialect: ""},
+		{Dialect: MySQL, Copy: true},
+		{Dialect: SQLite, Copy: true},
+	} {
+		var out strings.Builder
+		if err := WriteSQL(&out, sqlNames, opts); err == nil {
+			t.Errorf("%+v: no error", opts)
+		}
+		if out.Len() != 0 {
+			t.Errorf("%+v: wrote %q before failing", opts, out.String())
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// TranslitScheme picks the romanization table used for Cyrillic
+type TranslitScheme int
+
+const (
+	// SchemeBGN follows BGN/PCGN, which only needs plain ASCII letters
+	SchemeBGN TranslitScheme = iota
+	// SchemeISO9 follows ISO 9, one Latin letter (often with a diacritic)
+	// per Cyrillic letter
+	SchemeISO9
+)
+
+// cyrillicBGN covers Russian plus the extra Ukrainian, Belarusian and
+// Serbian letters
+var cyrillicBGN = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g", 'ў': "w",
+	'ђ': "dj", 'ј': "j", 'љ': "lj", 'њ': "nj", 'ћ': "c", 'џ': "dz",
+}
+
+// cyrillicISO9 lists the letters where ISO 9 differs from BGN/PCGN. Letters
+// without a precomposed capital, such as ǰ, are written as a base letter and
+// a combining mark so they can be upper-cased.
+var cyrillicISO9 = map[rune]string{
+	'ё': "ë", 'ж': "ž", 'й': "j", 'х': "h", 'ц': "c", 'ч': "č", 'ш': "š",
+	'щ': "ŝ", 'ъ': "ʺ", 'ь': "ʹ", 'э': "è", 'ю': "û", 'я': "â",
+	'і': "ì", 'ї': "ï", 'є': "ê", 'ґ': "g̀", 'ў': "ŭ",
+	'ђ': "đ", 'ј': "ǰ", 'љ': "l̂", 'њ': "n̂", 'ћ': "ć", 'џ': "d̂",
+}
+
+// greekELOT follows ELOT 743, which is also what BGN/PCGN uses for Greek
+var greekELOT = map[rune]string{
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+	'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+	'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	'ά': "a", 'έ': "e", 'ή': "i", 'ί': "i", 'ό': "o", 'ύ': "y", 'ώ': "o",
+	'ϊ': "i", 'ΐ': "i", 'ϋ': "y", 'ΰ': "y",
+}
+
+// greekVoiceless are the letters after which αυ, ευ and ηυ sound as af, ef
+// and if rather than av, ev and iv
+const greekVoiceless = "θκξπσςτφχψ"
+
+// greekNasalStops are read as b, d and g at the start of a word and as mb,
+// nd and ng inside it; γγ is always ng
+var greekNasalStops = map[string][2]string{
+	"μπ": {"b", "mb"}, "ντ": {"d", "nd"}, "γκ": {"g", "ng"}, "γγ": {"ng", "ng"},
+}
+
+// latinFold maps accented Latin letters to plain ASCII: Latin-1, Latin
+// Extended-A, the Romanian, Vietnamese and pinyin letters from Extended-B,
+// and the dotted letters used to romanize Arabic and Indic names
+var latinFold = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a", 'ǎ': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'ĉ': "c", 'ċ': "c", 'č': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ĕ': "e", 'ė': "e", 'ę': "e", 'ě': "e", 'ə': "e",
+	'ƒ': "f", 'ĝ': "g", 'ğ': "g", 'ġ': "g", 'ģ': "g", 'ǧ': "g", 'ĥ': "h", 'ħ': "h",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ĩ': "i", 'ī': "i", 'ĭ': "i", 'į': "i", 'ı': "i", 'ǐ': "i",
+	'ĳ': "ij", 'ĵ': "j", 'ǰ': "j", 'ķ': "k", 'ǩ': "k", 'ĸ': "k",
+	'ĺ': "l", 'ļ': "l", 'ľ': "l", 'ŀ': "l", 'ł': "l", 'ñ': "n", 'ń': "n", 'ņ': "n", 'ň': "n", 'ŉ': "n", 'ŋ': "ng",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ŏ': "o", 'ő': "o", 'ơ': "o", 'ǒ': "o", 'œ': "oe",
+	'ŕ': "r", 'ŗ': "r", 'ř': "r", 'ś': "s", 'ŝ': "s", 'ş': "s", 'ș': "s", 'š': "s", 'ſ': "s", 'ß': "ss",
+	'ţ': "t", 'ț': "t", 'ť': "t", 'ŧ': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ũ': "u", 'ū': "u", 'ŭ': "u", 'ů': "u", 'ű': "u", 'ų': "u", 'ư': "u", 'ǔ': "u",
+	'ŵ': "w", 'ý': "y", 'ÿ': "y", 'ŷ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+
+	// Vietnamese
+	'ạ': "a", 'ả': "a", 'ấ': "a", 'ầ': "a", 'ẩ': "a", 'ẫ': "a", 'ậ': "a", 'ắ': "a", 'ằ': "a", 'ẳ': "a", 'ẵ': "a", 'ặ': "a",
+	'ẹ': "e", 'ẻ': "e", 'ẽ': "e", 'ế': "e", 'ề': "e", 'ể': "e", 'ễ': "e", 'ệ': "e", 'ỉ': "i", 'ị': "i",
+	'ọ': "o", 'ỏ': "o", 'ố': "o", 'ồ': "o", 'ổ': "o", 'ỗ': "o", 'ộ': "o", 'ớ': "o", 'ờ': "o", 'ở': "o", 'ỡ': "o", 'ợ': "o",
+	'ụ': "u", 'ủ': "u", 'ứ': "u", 'ừ': "u", 'ử': "u", 'ữ': "u", 'ự': "u", 'ỳ': "y", 'ỵ': "y", 'ỷ': "y", 'ỹ': "y",
+
+	// Arabic and Indic romanization
+	'ḍ': "d", 'ḥ': "h", 'ḳ': "k", 'ḷ': "l", 'ṁ': "m", 'ṃ': "m", 'ṅ': "n", 'ṇ': "n", 'ṛ': "r", 'ṣ': "s", 'ṭ': "t", 'ẓ': "z",
+}
+
+// Transliterate rewrites Cyrillic and Greek text in Latin letters and strips
+// diacritics from Latin letters. With ascii set, the diacritics of the ISO 9
+// spellings and any combining accents are stripped too. A letter with no
+// known Latin spelling, such as 李, is kept as it is rather than lost.
+func Transliterate(s string, scheme TranslitScheme, ascii bool) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		lower := unicode.ToLower(r)
+
+		latin, n := greekDigraph(runes, i)
+		if n == 0 {
+			n = 1
+			if v, ok := scriptLetter(lower, scheme); ok {
+				latin = v
+			} else if r < 0x80 {
+				b.WriteRune(r)
+				i++
+				continue
+			} else if v, ok := latinFold[lower]; ok {
+				latin = v
+			} else {
+				if !ascii || !unicode.Is(unicode.Mn, r) {
+					b.WriteRune(r)
+				}
+				i++
+				continue
+			}
+		}
+
+		if ascii {
+			latin = foldLatin(latin)
+		}
+		if unicode.IsUpper(r) && latin != "" {
+			latin = matchCase(latin, runes, i, n)
+		}
+		b.WriteString(latin)
+		i += n
+	}
+	return b.String()
+}
+
+// matchCase capitalizes latin, the spelling of the n upper-case runes at
+// runes[i:]. In a word written in capitals the whole spelling is upper-cased,
+// so "ЖУКОВ" becomes "ZHUKOV" rather than "ZhUKOV".
+func matchCase(latin string, runes []rune, i, n int) string {
+	allCaps := false
+	if next := i + n; next < len(runes) && unicode.IsLetter(runes[next]) {
+		allCaps = unicode.IsUpper(runes[next])
+	} else if i > 0 {
+		allCaps = unicode.IsUpper(runes[i-1])
+	}
+	if allCaps {
+		return strings.ToUpper(latin)
+	}
+	first := []rune(latin)
+	first[0] = unicode.ToUpper(first[0])
+	return string(first)
+}
+
+// greekDigraph spells the Greek letter pairs that are not written letter by
+// letter: ου, αυ, ευ, ηυ (accented or not) and μπ, ντ, γκ, γγ. It returns
+// the spelling and the number of runes used, which is 0 when runes[i:] does
+// not start with one of them.
+func greekDigraph(runes []rune, i int) (string, int) {
+	if i+1 >= len(runes) {
+		return "", 0
+	}
+	first, second := unicode.ToLower(runes[i]), unicode.ToLower(runes[i+1])
+	if second == 'υ' || second == 'ύ' {
+		switch first {
+		case 'ο':
+			return "ou", 2
+		case 'α', 'ε', 'η':
+			vowel := greekELOT[first]
+			if i+2 >= len(runes) || !unicode.IsLetter(runes[i+2]) ||
+				strings.ContainsRune(greekVoiceless, unicode.ToLower(runes[i+2])) {
+				return vowel + "f", 2
+			}
+			return vowel + "v", 2
+		}
+	}
+	if spellings, ok := greekNasalStops[string([]rune{first, second})]; ok {
+		if i == 0 || !unicode.IsLetter(runes[i-1]) {
+			return spellings[0], 2
+		}
+		return spellings[1], 2
+	}
+	return "", 0
+}
+
+// scriptLetter looks up a lowercase Cyrillic or Greek letter
+func scriptLetter(r rune, scheme TranslitScheme) (string, bool) {
+	if scheme == SchemeISO9 {
+		if v, ok := cyrillicISO9[r]; ok {
+			return v, true
+		}
+	}
+	if v, ok := cyrillicBGN[r]; ok {
+		return v, true
+	}
+	v, ok := greekELOT[r]
+	return v, ok
+}
+
+// foldLatin strips the diacritics left over from the ISO 9 table
+func foldLatin(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if plain, ok := latinFold[r]; ok {
+			b.WriteString(plain)
+		} else if r < 0x80 {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// transliterateName replaces the fields of n with their Latin spelling,
+// keeping the original spelling when it changed. A field that would come out
+// empty, such as a lone "Ъ", keeps its original spelling instead.
+func transliterateName(n Name, scheme TranslitScheme) Name {
+	fname := nonEmpty(Transliterate(n.fname, scheme, true), n.fname)
+	lname := nonEmpty(Transliterate(n.lname, scheme, true), n.lname)
+	if fname != n.fname || lname != n.lname {
+		n.origFname, n.origLname = n.fname, n.lname
+		n.fname, n.lname = fname, lname
+	}
+	return n
+}
+
+func nonEmpty(s, fallback string) string {
+	if strings.TrimSpace(s) == "" {
+		return fallback
+	}
+	return s
+}
@@ -0,0 +1,85 @@
+package main
+
+import "testing"
+
+func TestTransliterate(t *testing.T) {
+	tests := []struct {
+		in     string
+		scheme TranslitScheme
+		ascii  bool
+		want   string
+	}{
+		{"Борис Ельцин", SchemeBGN, true, "Boris Eltsin"},
+		{"Щукин", SchemeBGN, true, "Shchukin"},
+		{"Жуков", SchemeBGN, true, "Zhukov"},
+		{"Жуков", SchemeISO9, false, "Žukov"},
+		{"Жуков", SchemeISO9, true, "Zukov"},
+		{"Юрий", SchemeISO9, false, "Ûrij"},
+		{"Объект", SchemeBGN, true, "Obekt"},
+		{"Їжак", SchemeBGN, true, "Yizhak"},
+		{"Σωκράτης", SchemeBGN, true, "Sokratis"},
+		{"Παπαδόπουλος", SchemeBGN, true, "Papadopoulos"},
+		{"José García", SchemeBGN, true, "Jose Garcia"},
+		{"Łukasz Wałęsa", SchemeBGN, true, "Lukasz Walesa"},
+		{"Straße", SchemeBGN, true, "Strasse"},
+		{"李 Smith", SchemeBGN, true, "李 Smith"},
+		{"李 Smith", SchemeBGN, false, "李 Smith"},
+		{"Ștefan Țiriac", SchemeBGN, true, "Stefan Tiriac"},
+		{"Nguyễn Thị Ánh", SchemeBGN, true, "Nguyen Thi Anh"},
+		{"Ħal Għaxaq", SchemeBGN, true, "Hal Ghaxaq"},
+		{"Dvořák", SchemeBGN, true, "Dvorak"},
+		{"Jose\u0301 Zo\u0308e", SchemeBGN, true, "Jose Zoe"},
+		{"Jose\u0301", SchemeBGN, false, "Jose\u0301"},
+		{"O'Brien-Smith", SchemeBGN, true, "O'Brien-Smith"},
+
+		// Capitals
+		{"ЖУКОВ", SchemeBGN, true, "ZHUKOV"},
+		{"ЛЕЩ", SchemeBGN, true, "LESHCH"},
+		{"ЮРИЙ ЩУКИН", SchemeBGN, true, "YURIY SHCHUKIN"},
+		{"Щ", SchemeBGN, true, "Shch"},
+		{"ЮРИЙ", SchemeISO9, false, "ÛRIJ"},
+
+		// ISO 9 for Ukrainian and Serbian letters
+		{"Іван", SchemeISO9, false, "Ìvan"},
+		{"Іван", SchemeISO9, true, "Ivan"},
+		{"Ђорђе", SchemeISO9, false, "Đorđe"},
+		{"Јован", SchemeISO9, false, "J\u030covan"},
+		{"Јован", SchemeISO9, true, "Jovan"},
+		{"Љиљана Њешић", SchemeISO9, false, "L\u0302il\u0302ana N\u0302ešić"},
+		{"Џаџић", SchemeISO9, true, "Dadic"},
+		{"Љиљана", SchemeBGN, true, "Ljiljana"},
+
+		// Greek letter pairs
+		{"Μουσούρης", SchemeBGN, true, "Mousouris"},
+		{"Ευάγγελος", SchemeBGN, true, "Evangelos"},
+		{"Ευθύμιος", SchemeBGN, true, "Efthymios"},
+		{"Μαύρος", SchemeBGN, true, "Mavros"},
+		{"Αυτός", SchemeBGN, true, "Aftos"},
+		{"Μπακογιάννη", SchemeBGN, true, "Bakogianni"},
+		{"Λαμπράκης", SchemeBGN, true, "Lambrakis"},
+		{"ΚΑΡΑΜΠΑΣ", SchemeBGN, true, "KARAMBAS"},
+		{"Ντόρα", SchemeBGN, true, "Dora"},
+		{"ΕΥΑ", SchemeBGN, true, "EVA"},
+		{"Προϋπόθεση", SchemeBGN, true, "Proypothesi"},
+	}
+	for _, tt := range tests {
+		if got := Transliterate(tt.in, tt.scheme, tt.ascii); got != tt.want {
+			t.Errorf("Transliterate(%q, %d, %v) = %q, want %q", tt.in, tt.scheme, tt.ascii, got, tt.want)
+		}
+	}
+}
+
+func TestTransliterateNameKeepsOriginal(t *testing.T) {
+	n := transliterateName(Name{fname: "Анна", lname: "Павлова"}, SchemeBGN)
+	if n.fname != "Anna" || n.lname != "Pavlova" || n.origFname != "Анна" || n.origLname != "Павлова" {
+		t.Errorf("got %+v", n)
+	}
+	n = transliterateName(Name{fname: "李", lname: "Ъ"}, SchemeBGN)
+	if n.fname != "李" || n.lname != "Ъ" {
+		t.Errorf("fields without a Latin spelling: got %+v", n)
+	}
+	n = transliterateName(Name{fname: "Ann", lname: "Lee"}, SchemeBGN)
+	if n.origFname != "" || n.origLname != "" {
+		t.Errorf("ASCII name got an original spelling: %+v", n)
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// UsernameOptions controls how login names are built from a Name
+type UsernameOptions struct {
+	Pattern string // e.g. "{first[0]}{last}" or "{first}.{last}"
+	MaxLen  int    // 0 means no limit
+	Allowed string // characters allowed besides a-z and 0-9
+	Domain  string // when set, an email address is built as well
+}
+
+// UsernameGenerator hands out unique usernames, remembering every name it has
+// given out or been told is taken
+type UsernameGenerator struct {
+	opts  UsernameOptions
+	taken map[string]bool
+}
+
+// Account is the result of generating a username for one Name
+type Account struct {
+	Person   Name
+	Username string
+	Email    string
+}
+
+// NewUsernameGenerator checks the pattern and returns a generator that avoids
+// the already taken usernames
+func NewUsernameGenerator(opts UsernameOptions, taken []string) (*UsernameGenerator, error) {
+	if opts.Pattern == "" {
+		opts.Pattern = "{first[0]}{last}"
+	}
+	if _, err := expandPattern(opts.Pattern, nameFields{}); err != nil {
+		return nil, err
+	}
+	g := &UsernameGenerator{opts: opts, taken: make(map[string]bool)}
+	for _, t := range taken {
+		g.taken[strings.ToLower(strings.TrimSpace(t))] = true
+	}
+	return g, nil
+}
+
+// Generate returns a unique username for n. Collisions are resolved first by
+// adding middle initials and then by numeric suffixes 2, 3, 4, ...
+func (g *UsernameGenerator) Generate(n Name) (Account, error) {
+	fields := splitName(n)
+
+	base, err := g.render(g.opts.Pattern, fields)
+	if err != nil {
+		return Account{}, err
+	}
+	if base == "" {
+		return Account{}, fmt.Errorf("no usable characters in %q %q", n.fname, n.lname)
+	}
+
+	candidates := []string{base}
+	if fields.middle != "" {
+		if withMiddle, err := g.render(middlePattern(g.opts.Pattern), fields); err == nil && withMiddle != base {
+			candidates = append(candidates, withMiddle)
+		}
+	}
+	for _, c := range candidates {
+		if !g.taken[c] {
+			return g.claim(n, c), nil
+		}
+	}
+
+	// Fall back to numeric suffixes, shortening the base so it still fits
+	for i := 2; ; i++ {
+		suffix := strconv.Itoa(i)
+		stem := base
+		if g.opts.MaxLen > 0 && utf8.RuneCountInString(stem)+len(suffix) > g.opts.MaxLen {
+			if len(suffix) >= g.opts.MaxLen {
+				return Account{}, fmt.Errorf("cannot find a free username for %q %q", n.fname, n.lname)
+			}
+			stem = truncate(stem, g.opts.MaxLen-len(suffix))
+		}
+		if c := stem + suffix; !g.taken[c] {
+			return g.claim(n, c), nil
+		}
+	}
+}
+
+// claim marks username as taken and builds the account for it
+func (g *UsernameGenerator) claim(n Name, username string) Account {
+	g.taken[username] = true
+	acc := Account{Person: n, Username: username}
+	if g.opts.Domain != "" {
+		acc.Email = username + "@" + g.opts.Domain
+	}
+	return acc
+}
+
+// render expands a pattern and cleans the result up to the allowed characters
+func (g *UsernameGenerator) render(pattern string, fields nameFields) (string, error) {
+	raw, err := expandPattern(pattern, fields)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, r := range strings.ToLower(Transliterate(raw, SchemeBGN, true)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || strings.ContainsRune(g.opts.Allowed, r) {
+			b.WriteRune(r)
+		}
+	}
+	s := b.String()
+	if g.opts.MaxLen > 0 {
+		// -allow may let in multi-byte letters, so count runes, not bytes
+		s = truncate(s, g.opts.MaxLen)
+	}
+	return s, nil
+}
+
+// nameFields holds the parts of a name that patterns can refer to
+type nameFields struct {
+	first, middle, last string
+}
+
+// surnameParticles start compound surnames such as "de la Cruz" or
+// "van Dijk"
+var surnameParticles = map[string]bool{
+	"al": true, "bin": true, "da": true, "das": true, "de": true, "del": true,
+	"della": true, "der": true, "di": true, "do": true, "dos": true, "du": true,
+	"el": true, "ibn": true, "la": true, "le": true, "st": true, "st.": true,
+	"ten": true, "ter": true, "van": true, "von": true,
+}
+
+// splitName treats every word of the last name but the final one as a
+// middle name, so "John Ronald Tolkien" has middle name "Ronald". Particles
+// in front of the final word stay with it: "Maria de la Cruz" has last name
+// "de la Cruz" and no middle name.
+func splitName(n Name) nameFields {
+	f := nameFields{first: n.fname, last: n.lname}
+	words := strings.Fields(n.lname)
+	if len(words) > 1 {
+		start := len(words) - 1
+		for start > 0 && surnameParticles[strings.ToLower(words[start-1])] {
+			start--
+		}
+		f.middle = strings.Join(words[:start], " ")
+		f.last = strings.Join(words[start:], " ")
+	}
+	return f
+}
+
+// middlePattern inserts the middle initial in front of the last name
+func middlePattern(pattern string) string {
+	i := strings.Index(pattern, "{last")
+	if i < 0 {
+		return pattern + "{middle[0]}"
+	}
+	return pattern[:i] + "{middle[0]}" + pattern[i:]
+}
+
+// expandPattern replaces {field}, {field[N]} (the Nth letter) and {field[:N]}
+// (the first N letters) with parts of the name
+func expandPattern(pattern string, fields nameFields) (string, error) {
+	var b strings.Builder
+	for {
+		open := strings.IndexByte(pattern, '{')
+		if open < 0 {
+			b.WriteString(pattern)
+			return b.String(), nil
+		}
+		end := strings.IndexByte(pattern[open:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unclosed '{' in pattern")
+		}
+		b.WriteString(pattern[:open])
+		value, err := expandField(pattern[open+1:open+end], fields)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(value)
+		pattern = pattern[open+end+1:]
+	}
+}
+
+// expandField evaluates a single placeholder such as "first[0]"
+func expandField(spec string, fields nameFields) (string, error) {
+	field, index := spec, ""
+	if i := strings.IndexByte(spec, '['); i >= 0 {
+		if !strings.HasSuffix(spec, "]") {
+			return "", fmt.Errorf("bad placeholder {%s}", spec)
+		}
+		field, index = spec[:i], spec[i+1:len(spec)-1]
+	}
+
+	var value string
+	switch field {
+	case "first":
+		value = fields.first
+	case "middle":
+		value = fields.middle
+	case "last":
+		value = fields.last
+	default:
+		return "", fmt.Errorf("unknown field %q in pattern", field)
+	}
+	if index == "" {
+		return value, nil
+	}
+
+	letters := []rune(value)
+	if strings.HasPrefix(index, ":") {
+		n, err := strconv.Atoi(index[1:])
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("bad placeholder {%s}", spec)
+		}
+		return string(letters[:min(n, len(letters))]), nil
+	}
+	n, err := strconv.Atoi(index)
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("bad placeholder {%s}", spec)
+	}
+	if n >= len(letters) {
+		return "", nil
+	}
+	return string(letters[n]), nil
+}
+
+// readLines returns the non-blank lines of a file
+func readLines(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// usernamesCommand implements "usernames [flags] names.txt"
+func usernamesCommand(args []string) error {
+	fs := flag.NewFlagSet("usernames", flag.ContinueOnError)
+	var opts UsernameOptions
+	fs.StringVar(&opts.Pattern, "pattern", "{first[0]}{last}", "username pattern")
+	fs.IntVar(&opts.MaxLen, "max", 0, "maximum username length (0 for no limit)")
+	fs.StringVar(&opts.Allowed, "allow", "", "extra allowed characters, e.g. \"._-\"")
+	fs.StringVar(&opts.Domain, "domain", "", "email domain")
+	takenFile := fs.String("taken", "", "file with usernames that are already taken, one per line")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: usernames [flags] names.txt")
+	}
+
+	var taken []string
+	if *takenFile != "" {
+		var err error
+		if taken, err = readLines(*takenFile); err != nil {
+			return err
+		}
+	}
+
+	names, err := loadNames(fs.Arg(0), LoadOptions{})
+	if err != nil {
+		return err
+	}
+	gen, err := NewUsernameGenerator(opts, taken)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range names {
+		acc, err := gen.Generate(n)
+		if err != nil {
+			fmt.Println("Skipping:", err)
+			continue
+		}
+		fmt.Printf("%-20s %-20s %-20s %s\n", n.fname, n.lname, acc.Username, acc.Email)
+	}
+	return nil
+}
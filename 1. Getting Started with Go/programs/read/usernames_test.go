@@ -0,0 +1,80 @@
+package main
+
+import "testing"
+
+func TestSplitNameKeepsParticles(t *testing.T) {
+	tests := []struct {
+		fname, lname string
+		want         nameFields
+	}{
+		{"Maria", "de la Cruz", nameFields{"Maria", "", "de la Cruz"}},
+		{"Ludwig", "van Beethoven", nameFields{"Ludwig", "", "van Beethoven"}},
+		{"John", "Ronald Tolkien", nameFields{"John", "Ronald", "Tolkien"}},
+		{"Anna", "Maria von Trapp", nameFields{"Anna", "Maria", "von Trapp"}},
+		{"Jan", "Van", nameFields{"Jan", "", "Van"}},
+	}
+	for _, tt := range tests {
+		if got := splitName(Name{fname: tt.fname, lname: tt.lname}); got != tt.want {
+			t.Errorf("splitName(%s %s) = %+v, want %+v", tt.fname, tt.lname, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateResolvesCollisions(t *testing.T) {
+	g, err := NewUsernameGenerator(UsernameOptions{Domain: "example.com"}, []string{" JTolkien "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		fname, lname, want string
+	}{
+		{"Maria", "de la Cruz", "mdelacruz"},
+		{"Manuel", "de la Cruz", "mdelacruz2"},
+		{"John", "Ronald Tolkien", "jrtolkien"}, // jtolkien was taken, so the middle initial goes in
+		{"Jane", "Rose Tolkien", "jtolkien2"},   // both taken: the suffix goes on the base
+		{"Jim", "Tolkien", "jtolkien3"},         // no middle name to try
+		{"Zoë", "Ångström", "zangstrom"},
+	}
+	for _, tt := range tests {
+		acc, err := g.Generate(Name{fname: tt.fname, lname: tt.lname})
+		if err != nil {
+			t.Errorf("%s %s: %v", tt.fname, tt.lname, err)
+			continue
+		}
+		if acc.Username != tt.want || acc.Email != tt.want+"@example.com" {
+			t.Errorf("%s %s: got %s <%s>, want %s", tt.fname, tt.lname, acc.Username, acc.Email, tt.want)
+		}
+	}
+}
+
+func TestGenerateSuffixFitsMaxLen(t *testing.T) {
+	g, err := NewUsernameGenerator(UsernameOptions{Pattern: "{first}.{last}", MaxLen: 8, Allowed: "."}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for range 11 {
+		acc, err := g.Generate(Name{fname: "Alexander", lname: "Smith"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, acc.Username)
+	}
+	want := []string{"alexande", "alexand2", "alexand3"}
+	for i, w := range want {
+		if got[i] != w {
+			t.Errorf("username %d = %q, want %q", i+1, got[i], w)
+		}
+	}
+	if last := got[10]; last != "alexan11" {
+		t.Errorf("username 11 = %q, want \"alexan11\"", last)
+	}
+}
+
+func TestUsernamePatternErrors(t *testing.T) {
+	for _, pattern := range []string{"{first", "{nick}", "{first[x]}", "{last[:-1]}", "{first[0}"} {
+		if _, err := NewUsernameGenerator(UsernameOptions{Pattern: pattern}, nil); err == nil {
+			t.Errorf("pattern %q: no error", pattern)
+		}
+	}
+}
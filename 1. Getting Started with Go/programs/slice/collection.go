@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Mode decides what happens to repeated values
+type Mode string
+
+const (
+	ModeList     Mode = "list"     // keep every value, duplicates included
+	ModeSet      Mode = "set"      // refuse values that are already there
+	ModeMultiset Mode = "multiset" // keep duplicates and show them as counts
+)
+
+// counts returns how many times each value occurs
+func counts(nums []int) map[int]int {
+	c := make(map[int]int)
+	for _, n := range nums {
+		c[n]++
+	}
+	return c
+}
+
+// fromCounts turns counts back into a sorted slice
+func fromCounts(c map[int]int) []int {
+	var nums []int
+	for n, k := range c {
+		for range k {
+			nums = append(nums, n)
+		}
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+// contains reports whether the sorted slice nums holds v
+func contains(nums []int, v int) bool {
+	i := sort.SearchInts(nums, v)
+	return i < len(nums) && nums[i] == v
+}
+
+// removeOne removes one occurrence of v from the sorted slice nums
+func removeOne(nums []int, v int) ([]int, bool) {
+	i := sort.SearchInts(nums, v)
+	if i == len(nums) || nums[i] != v {
+		return nums, false
+	}
+	return append(nums[:i], nums[i+1:]...), true
+}
+
+// removeAll removes every occurrence of v and returns how many there were
+func removeAll(nums []int, v int) ([]int, int) {
+	i := sort.SearchInts(nums, v)
+	j := i
+	for j < len(nums) && nums[j] == v {
+		j++
+	}
+	return append(nums[:i], nums[j:]...), j - i
+}
+
+// combine builds a new sorted slice from the counts of a and b. For sets
+// every count is 0 or 1, so max, min and minus give the usual union,
+// intersection and difference; lists and multisets keep the counts.
+func combine(a, b []int, op func(x, y int) int) []int {
+	ca, cb := counts(a), counts(b)
+	out := make(map[int]int)
+	for n := range ca {
+		out[n] = op(ca[n], cb[n])
+	}
+	for n := range cb {
+		out[n] = op(ca[n], cb[n])
+	}
+	return fromCounts(out)
+}
+
+func union(a, b []int) []int {
+	return combine(a, b, func(x, y int) int { return max(x, y) })
+}
+
+func intersection(a, b []int) []int {
+	return combine(a, b, func(x, y int) int { return min(x, y) })
+}
+
+func difference(a, b []int) []int {
+	return combine(a, b, func(x, y int) int { return max(x-y, 0) })
+}
+
+// formatCollection shows nums the way the mode thinks of it: a list as
+// [1 2 2], a set as {1 2} and a multiset as {1 2×2}
+func formatCollection(nums []int, mode Mode) string {
+	switch mode {
+	case ModeSet:
+		return "{" + strings.Trim(fmt.Sprint(nums), "[]") + "}"
+	case ModeMultiset:
+		var parts []string
+		for i := 0; i < len(nums); {
+			j := i
+			for j < len(nums) && nums[j] == nums[i] {
+				j++
+			}
+			if j-i > 1 {
+				parts = append(parts, fmt.Sprintf("%d×%d", nums[i], j-i))
+			} else {
+				parts = append(parts, strconv.Itoa(nums[i]))
+			}
+			i = j
+		}
+		return "{" + strings.Join(parts, " ") + "}"
+	}
+	return fmt.Sprint(nums)
+}
+
+// printFrequencies prints a table of each value and how often it occurs
+func printFrequencies(w io.Writer, nums []int) {
+	if len(nums) == 0 {
+		fmt.Fprintln(w, "  (empty)")
+		return
+	}
+	c := counts(nums)
+	values := make([]int, 0, len(c))
+	for n := range c {
+		values = append(values, n)
+	}
+	sort.Ints(values)
+	fmt.Fprintf(w, "  %10s  %5s  %6s\n", "value", "count", "share")
+	for _, n := range values {
+		fmt.Fprintf(w, "  %10d  %5d  %5.1f%%\n", n, c[n], 100*float64(c[n])/float64(len(nums)))
+	}
+}
+
+// loadInts reads whitespace-separated integers from a file, or a file
+// written by saveInts, sorted, with duplicates dropped in set mode
+func loadInts(filename string, mode Mode) ([]int, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if set := savedSet(data); set != nil {
+		if err := set.UnmarshalBinary(data); err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		nums := make([]int, 0, set.Len())
+		set.Each(func(v uint32) bool {
+			nums = append(nums, int(v))
+			return true
+		})
+		return nums, nil
+	}
+	var nums []int
+	for _, field := range strings.Fields(string(data)) {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q is not an integer", filename, field)
+		}
+		nums = append(nums, n)
+	}
+	sort.Ints(nums)
+	if mode == ModeSet {
+		nums = slices.Compact(nums)
+	}
+	return nums, nil
+}
+
+// savedContainer is what saveInts and loadInts need from PackedInts and
+// Bitmap
+type savedContainer interface {
+	Insert(v uint32) bool
+	Each(fn func(uint32) bool)
+	Len() int
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// savedSet returns an empty container of the kind whose magic starts data,
+// or nil for a text file
+func savedSet(data []byte) savedContainer {
+	switch {
+	case bytes.HasPrefix(data, []byte(packedMagic)):
+		return &PackedInts{}
+	case bytes.HasPrefix(data, []byte(bitmapMagic)):
+		return &Bitmap{}
+	}
+	return nil
+}
+
+// saveInts writes the sorted slice nums to a file in whichever of the
+// PackedInts and Bitmap forms is smaller, and returns the one it chose.
+// Both hold sets of uint32, so repeats and values outside that range are
+// refused rather than lost.
+func saveInts(filename string, nums []int) (string, error) {
+	packed, bitmap := &PackedInts{}, &Bitmap{}
+	for i, n := range nums {
+		if n < 0 || uint64(n) > math.MaxUint32 {
+			return "", fmt.Errorf("%d does not fit: saved sets hold 0 to %d", n, uint32(math.MaxUint32))
+		}
+		if i > 0 && nums[i-1] == n {
+			return "", fmt.Errorf("%d is repeated: saved sets hold each value once", n)
+		}
+		packed.Insert(uint32(n))
+		bitmap.Insert(uint32(n))
+	}
+	packedData, err := packed.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	bitmapData, err := bitmap.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	kind, data := "packed", packedData
+	if len(bitmapData) < len(packedData) {
+		kind, data = "bitmap", bitmapData
+	}
+	return kind, os.WriteFile(filename, data, 0o644)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRemove(t *testing.T) {
+	tests := []struct {
+		name    string
+		nums    []int
+		v       int
+		wantOne []int
+		found   bool
+		wantAll []int
+		removed int
+	}{
+		{"set value", []int{1, 2, 3}, 2, []int{1, 3}, true, []int{1, 3}, 1},
+		{"list duplicate", []int{1, 2, 2, 2, 3}, 2, []int{1, 2, 2, 3}, true, []int{1, 3}, 3},
+		{"first and last", []int{4, 4, 5}, 4, []int{4, 5}, true, []int{5}, 2},
+		{"missing", []int{1, 3}, 2, []int{1, 3}, false, []int{1, 3}, 0},
+		{"past the end", []int{1, 3}, 9, []int{1, 3}, false, []int{1, 3}, 0},
+		{"empty", nil, 1, nil, false, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := removeOne(slices.Clone(tt.nums), tt.v)
+			if !slices.Equal(got, tt.wantOne) || found != tt.found {
+				t.Errorf("removeOne: got %v, %v, want %v, %v", got, found, tt.wantOne, tt.found)
+			}
+			got, removed := removeAll(slices.Clone(tt.nums), tt.v)
+			if !slices.Equal(got, tt.wantAll) || removed != tt.removed {
+				t.Errorf("removeAll: got %v, %d, want %v, %d", got, removed, tt.wantAll, tt.removed)
+			}
+		})
+	}
+}
+
+func TestCombine(t *testing.T) {
+	tests := []struct {
+		name                       string
+		a, b                       []int
+		union, intersection, minus []int
+	}{
+		{"sets", []int{1, 2, 3}, []int{2, 3, 4},
+			[]int{1, 2, 3, 4}, []int{2, 3}, []int{1}},
+		{"disjoint sets", []int{1}, []int{2},
+			[]int{1, 2}, nil, []int{1}},
+		{"multisets keep counts", []int{1, 1, 2, 2, 2}, []int{1, 2, 3, 3},
+			[]int{1, 1, 2, 2, 2, 3, 3}, []int{1, 2}, []int{1, 2, 2}},
+		{"lists work as multisets", []int{5, 5, 5}, []int{5, 5},
+			[]int{5, 5, 5}, []int{5, 5}, []int{5}},
+		{"empty other", []int{1, 1}, nil,
+			[]int{1, 1}, nil, []int{1, 1}},
+		{"both empty", nil, nil, nil, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := union(tt.a, tt.b); !slices.Equal(got, tt.union) {
+				t.Errorf("union: got %v, want %v", got, tt.union)
+			}
+			if got := intersection(tt.a, tt.b); !slices.Equal(got, tt.intersection) {
+				t.Errorf("intersection: got %v, want %v", got, tt.intersection)
+			}
+			if got := difference(tt.a, tt.b); !slices.Equal(got, tt.minus) {
+				t.Errorf("difference: got %v, want %v", got, tt.minus)
+			}
+		})
+	}
+}
+
+func TestFormatCollection(t *testing.T) {
+	tests := []struct {
+		nums []int
+		mode Mode
+		want string
+	}{
+		{[]int{1, 2, 2}, ModeList, "[1 2 2]"},
+		{nil, ModeList, "[]"},
+		{[]int{-1, 2}, ModeSet, "{-1 2}"},
+		{nil, ModeSet, "{}"},
+		{[]int{1, 2, 2, 3, 3, 3}, ModeMultiset, "{1 2×2 3×3}"},
+		{[]int{7}, ModeMultiset, "{7}"},
+		{nil, ModeMultiset, "{}"},
+	}
+	for _, tt := range tests {
+		if got := formatCollection(tt.nums, tt.mode); got != tt.want {
+			t.Errorf("formatCollection(%v, %s) = %q, want %q", tt.nums, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestPrintFrequencies(t *testing.T) {
+	var out strings.Builder
+	printFrequencies(&out, []int{3, 1, 3, 3})
+	want := "" +
+		"       value  count   share\n" +
+		"           1      1   25.0%\n" +
+		"           3      3   75.0%\n"
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	printFrequencies(&out, nil)
+	if out.String() != "  (empty)\n" {
+		t.Errorf("empty: got %q", out.String())
+	}
+}
+
+func TestLoadInts(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.txt")
+	if err := os.WriteFile(good, []byte("3 1\n-2\t3\n\n1 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		mode Mode
+		want []int
+	}{
+		{ModeList, []int{-2, 1, 1, 3, 3, 3}},
+		{ModeMultiset, []int{-2, 1, 1, 3, 3, 3}},
+		{ModeSet, []int{-2, 1, 3}},
+	}
+	for _, tt := range tests {
+		got, err := loadInts(good, tt.mode)
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, %v, want %v", tt.mode, got, err, tt.want)
+		}
+	}
+
+	bad := filepath.Join(dir, "bad.txt")
+	if err := os.WriteFile(bad, []byte("1 2 x3"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadInts(bad, ModeList); err == nil || !strings.Contains(err.Error(), `"x3"`) {
+		t.Errorf("bad file: got %v", err)
+	}
+	if _, err := loadInts(filepath.Join(dir, "missing.txt"), ModeList); err == nil {
+		t.Error("missing file: no error")
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	dense := make([]int, 1<<16)
+	for i := range dense {
+		dense[i] = 1<<16 + i
+	}
+	tests := []struct {
+		name string
+		nums []int
+		kind string
+	}{
+		{"empty", nil, "packed"},
+		{"sparse", []int{0, 5, 1 << 20, 1<<32 - 1}, "packed"},
+		{"dense", dense, "bitmap"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(dir, tt.name)
+			kind, err := saveInts(file, tt.nums)
+			if err != nil || kind != tt.kind {
+				t.Fatalf("saveInts: got %q, %v, want %q", kind, err, tt.kind)
+			}
+			for _, mode := range []Mode{ModeList, ModeSet, ModeMultiset} {
+				got, err := loadInts(file, mode)
+				if err != nil || len(got) != len(tt.nums) || len(got) > 0 && !slices.Equal(got, tt.nums) {
+					t.Errorf("%s: loaded %d values, %v, want %d", mode, len(got), err, len(tt.nums))
+				}
+			}
+		})
+	}
+
+	for _, nums := range [][]int{{-1, 2}, {1, 1 << 32}, {1, 2, 2}} {
+		if _, err := saveInts(filepath.Join(dir, "bad"), nums); err == nil {
+			t.Errorf("saveInts(%v): no error", nums)
+		}
+	}
+
+	damaged := filepath.Join(dir, "damaged")
+	if err := os.WriteFile(damaged, []byte(packedMagic+"\x05"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadInts(damaged, ModeSet); err == nil {
+		t.Error("damaged file: no error")
+	}
+}
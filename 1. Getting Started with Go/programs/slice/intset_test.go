@@ -0,0 +1,430 @@
+package main
+
+import (
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// intSet is what the tests and benchmarks need from each container
+type intSet interface {
+	Insert(v uint32) bool
+	Contains(v uint32) bool
+	Rank(v uint32) int
+	Each(fn func(uint32) bool)
+	Len() int
+	SizeBytes() int
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// sortedInts is the plain []int that slice.go uses, as an intSet to compare
+// the compressed sets with
+type sortedInts struct {
+	nums []int
+}
+
+func (s *sortedInts) Insert(v uint32) bool {
+	i, found := slices.BinarySearch(s.nums, int(v))
+	if found {
+		return false
+	}
+	s.nums = slices.Insert(s.nums, i, int(v))
+	return true
+}
+
+func (s *sortedInts) Contains(v uint32) bool { return contains(s.nums, int(v)) }
+func (s *sortedInts) Rank(v uint32) int      { return sort.SearchInts(s.nums, int(v)+1) }
+func (s *sortedInts) Len() int               { return len(s.nums) }
+func (s *sortedInts) SizeBytes() int         { return cap(s.nums) * intSize }
+
+func (s *sortedInts) Each(fn func(uint32) bool) {
+	for _, n := range s.nums {
+		if !fn(uint32(n)) {
+			return
+		}
+	}
+}
+
+// MarshalBinary writes every value as 8 bytes, like dumping the slice
+func (s *sortedInts) MarshalBinary() ([]byte, error) {
+	out := make([]byte, 0, 8*len(s.nums))
+	for _, n := range s.nums {
+		out = binary.LittleEndian.AppendUint64(out, uint64(n))
+	}
+	return out, nil
+}
+
+func (s *sortedInts) UnmarshalBinary(data []byte) error {
+	if len(data)%8 != 0 {
+		return fmt.Errorf("int file length is not a multiple of 8")
+	}
+	s.nums = make([]int, len(data)/8)
+	for i := range s.nums {
+		s.nums[i] = int(binary.LittleEndian.Uint64(data[8*i:]))
+	}
+	return nil
+}
+
+// setKind names a container and makes empty ones
+type setKind struct {
+	name string
+	make func() intSet
+}
+
+// compressedSets are the containers under test
+var compressedSets = []setKind{
+	{"packed", func() intSet { return &PackedInts{} }},
+	{"roaring", func() intSet { return &Bitmap{} }},
+}
+
+// benchSets are the containers to benchmark: the compressed ones and []int
+var benchSets = append([]setKind{{"[]int", func() intSet { return &sortedInts{} }}}, compressedSets...)
+
+// randomValues returns n values below limit in random order, repeats
+// included
+func randomValues(rng *rand.Rand, n, limit int) []uint32 {
+	values := make([]uint32, n)
+	for i := range values {
+		values[i] = uint32(rng.Intn(limit))
+	}
+	return values
+}
+
+// checkSet compares set with the sorted, distinct want: its length, the
+// values Each visits, and Contains and Rank for every value in want and
+// for the probes
+func checkSet(t *testing.T, set intSet, want []uint32, probes []uint32) {
+	t.Helper()
+	if set.Len() != len(want) {
+		t.Errorf("Len = %d, want %d", set.Len(), len(want))
+	}
+	var got []uint32
+	set.Each(func(v uint32) bool {
+		got = append(got, v)
+		return true
+	})
+	if !slices.Equal(got, want) {
+		t.Errorf("Each visits %d values, want %d in order", len(got), len(want))
+	}
+	for _, v := range append(slices.Clone(want), probes...) {
+		i, found := slices.BinarySearch(want, v)
+		if found {
+			i++
+		}
+		if set.Contains(v) != found {
+			t.Errorf("Contains(%d) = %v, want %v", v, !found, found)
+		}
+		if rank := set.Rank(v); rank != i {
+			t.Errorf("Rank(%d) = %d, want %d", v, rank, i)
+		}
+	}
+}
+
+func TestSetsMatchSortedSlice(t *testing.T) {
+	distributions := []struct {
+		name  string
+		n     int
+		limit int
+	}{
+		{"small", 50, 100},
+		{"sparse", 5000, 1 << 30},
+		{"dense", 20000, 30000},
+		{"across containers", 30000, 1 << 18},
+		{"top of range", 2000, 1 << 32},
+	}
+	for _, dist := range distributions {
+		for _, s := range compressedSets {
+			t.Run(dist.name+"/"+s.name, func(t *testing.T) {
+				rng := rand.New(rand.NewSource(1))
+				set, ref := s.make(), &sortedInts{}
+				for _, v := range randomValues(rng, dist.n, dist.limit) {
+					if got, want := set.Insert(v), ref.Insert(v); got != want {
+						t.Fatalf("Insert(%d) = %v, want %v", v, got, want)
+					}
+				}
+				want := make([]uint32, len(ref.nums))
+				for i, n := range ref.nums {
+					want[i] = uint32(n)
+				}
+				checkSet(t, set, want, randomValues(rng, 1000, dist.limit))
+			})
+		}
+	}
+}
+
+func TestEmptySets(t *testing.T) {
+	for _, s := range compressedSets {
+		set := s.make()
+		if set.Len() != 0 || set.Contains(0) || set.Rank(1<<32-1) != 0 {
+			t.Errorf("%s: empty set is not empty", s.name)
+		}
+		set.Each(func(uint32) bool {
+			t.Errorf("%s: Each visits a value of an empty set", s.name)
+			return false
+		})
+	}
+}
+
+func TestEachStopsEarly(t *testing.T) {
+	for _, s := range compressedSets {
+		set := s.make()
+		for v := range uint32(1000) {
+			set.Insert(v * 100)
+		}
+		visited := 0
+		set.Each(func(v uint32) bool {
+			visited++
+			return v < 500*100
+		})
+		if visited != 501 {
+			t.Errorf("%s: Each visited %d values after being told to stop at 501", s.name, visited)
+		}
+	}
+}
+
+func TestPackedIntsSplitsBlocks(t *testing.T) {
+	p := &PackedInts{}
+	var want []uint32
+	for v := range uint32(blockSize) {
+		p.Insert(2 * v)
+		want = append(want, 2*v)
+	}
+	if len(p.blocks) != 1 || p.blocks[0].count != blockSize {
+		t.Fatalf("%d sorted values make %d blocks, want one full block", blockSize, len(p.blocks))
+	}
+
+	// One more value inside the full block splits it in two halves
+	p.Insert(5)
+	want = append(want, 5)
+	slices.Sort(want)
+	if len(p.blocks) != 2 || p.blocks[0].count+p.blocks[1].count != blockSize+1 {
+		t.Fatalf("after a split: %d blocks, want 2", len(p.blocks))
+	}
+	if p.blocks[0].last >= p.blocks[1].first {
+		t.Errorf("blocks overlap: first ends at %d, second starts at %d", p.blocks[0].last, p.blocks[1].first)
+	}
+	checkSet(t, p, want, []uint32{1, 3, 4, 6, 1000})
+
+	// Appending past the end of a full last block starts a new one
+	q := &PackedInts{}
+	for v := range uint32(blockSize + 1) {
+		q.Insert(v)
+	}
+	if len(q.blocks) != 2 || q.blocks[0].count != blockSize || q.blocks[1].count != 1 {
+		t.Errorf("appending %d values makes blocks %v, want %d and 1", blockSize+1, blockCounts(q), blockSize)
+	}
+}
+
+// blockCounts returns the number of values in each block
+func blockCounts(p *PackedInts) []int {
+	var counts []int
+	for _, b := range p.blocks {
+		counts = append(counts, b.count)
+	}
+	return counts
+}
+
+func TestBitmapSwitchesToBitmap(t *testing.T) {
+	b := &Bitmap{}
+	var want []uint32
+	for v := range uint32(arrayMax) {
+		b.Insert(3 * v)
+		want = append(want, 3*v)
+	}
+	if len(b.containers) != 1 || b.containers[0].bitmap != nil {
+		t.Fatalf("%d values in one container should still be an array", arrayMax)
+	}
+	// Inserting a value already there doesn't count towards the switch
+	if b.Insert(0) || b.containers[0].bitmap != nil {
+		t.Fatal("inserting a repeat changed the container")
+	}
+
+	b.Insert(1)
+	want = slices.Insert(want, 1, 1)
+	c := b.containers[0]
+	if c.bitmap == nil || c.array != nil || c.card != arrayMax+1 {
+		t.Fatalf("after %d values: bitmap %v, array %d, card %d, want a bitmap", arrayMax+1, c.bitmap != nil, len(c.array), c.card)
+	}
+	checkSet(t, b, want, []uint32{2, 4, 63, 64, 65, 3*arrayMax - 1, 1 << 16})
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, s := range compressedSets {
+		for _, n := range []int{0, 1, blockSize + 1, 3 * arrayMax} {
+			t.Run(fmt.Sprintf("%s/%d", s.name, n), func(t *testing.T) {
+				set, ref := s.make(), &sortedInts{}
+				for _, v := range randomValues(rng, n, 4*arrayMax) {
+					set.Insert(v)
+					ref.Insert(v)
+				}
+				data, err := set.MarshalBinary()
+				if err != nil {
+					t.Fatal(err)
+				}
+				loaded := s.make()
+				if err := loaded.UnmarshalBinary(data); err != nil {
+					t.Fatal(err)
+				}
+				want := make([]uint32, len(ref.nums))
+				for i, n := range ref.nums {
+					want[i] = uint32(n)
+				}
+				checkSet(t, loaded, want, []uint32{0, 1, 1 << 20})
+			})
+		}
+	}
+}
+
+func TestUnmarshalRejectsBadInput(t *testing.T) {
+	for _, s := range compressedSets {
+		set := s.make()
+		for v := range uint32(arrayMax + 10) {
+			set.Insert(v * 2) // one bitmap container, then an array one
+		}
+		set.Insert(1 << 20)
+		data, err := set.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Every truncation, and anything added at the end
+		for n := range len(data) {
+			if err := s.make().UnmarshalBinary(data[:n]); err == nil {
+				t.Errorf("%s: accepted the first %d of %d bytes", s.name, n, len(data))
+			}
+		}
+		if err := s.make().UnmarshalBinary(append(slices.Clone(data), 0)); err == nil {
+			t.Errorf("%s: accepted a trailing byte", s.name)
+		}
+		bad := slices.Clone(data)
+		bad[0] = 'X'
+		if err := s.make().UnmarshalBinary(bad); err == nil {
+			t.Errorf("%s: accepted a bad magic", s.name)
+		}
+	}
+
+	// Values out of order or repeated
+	packed := []byte(packedMagic)
+	packed = append(packed, 1, 5, 3, 2, 1, 0) // one block from 5: gaps 1 and 0
+	if err := new(PackedInts).UnmarshalBinary(packed); err == nil {
+		t.Error("packed: accepted a zero gap")
+	}
+	packed = []byte(packedMagic)
+	packed = append(packed, 2, 9, 1, 0, 5, 1, 0) // blocks starting 9 and then 5
+	if err := new(PackedInts).UnmarshalBinary(packed); err == nil {
+		t.Error("packed: accepted blocks out of order")
+	}
+	bitmap := []byte(bitmapMagic)
+	bitmap = append(bitmap, 1, 0, 0, 0, 2, 7, 0, 3, 0) // array 7, 3
+	if err := new(Bitmap).UnmarshalBinary(bitmap); err == nil {
+		t.Error("roaring: accepted an unsorted array")
+	}
+	bitmap = []byte(bitmapMagic)
+	bitmap = append(bitmap, 1, 0, 0, 1, 5)
+	bitmap = append(bitmap, make([]byte, 8*bitmapWords)...) // 5 values claimed, none set
+	if err := new(Bitmap).UnmarshalBinary(bitmap); err == nil {
+		t.Error("roaring: accepted a bitmap whose cardinality is wrong")
+	}
+}
+
+// filledSets fills one container of each of benchSets with n random values
+// below limit, and returns them with some values to look up
+func filledSets(n, limit int) ([]intSet, []uint32) {
+	rng := rand.New(rand.NewSource(1))
+	values := randomValues(rng, n, limit)
+	sets := make([]intSet, len(benchSets))
+	for i, kind := range benchSets {
+		sets[i] = kind.make()
+		for _, v := range values {
+			sets[i].Insert(v)
+		}
+	}
+	return sets, randomValues(rng, 1000, limit)
+}
+
+// benchDistributions spread the same number of values thinly over a wide
+// range and densely over a narrow one
+var benchDistributions = []struct {
+	name     string
+	n, limit int
+}{
+	{"sparse", 100000, 10000000},
+	{"dense", 100000, 200000},
+}
+
+// reportSize adds the memory a set takes per value to the benchmark output
+func reportSize(b *testing.B, set intSet) {
+	b.ReportMetric(float64(set.SizeBytes())/float64(set.Len()), "bytes/value")
+}
+
+func BenchmarkContains(b *testing.B) {
+	for _, dist := range benchDistributions {
+		sets, probes := filledSets(dist.n, dist.limit)
+		for i, set := range sets {
+			b.Run(dist.name+"/"+benchSets[i].name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					set.Contains(probes[i%len(probes)])
+				}
+				reportSize(b, set)
+			})
+		}
+	}
+}
+
+func BenchmarkRank(b *testing.B) {
+	for _, dist := range benchDistributions {
+		sets, probes := filledSets(dist.n, dist.limit)
+		for i, set := range sets {
+			b.Run(dist.name+"/"+benchSets[i].name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					set.Rank(probes[i%len(probes)])
+				}
+				reportSize(b, set)
+			})
+		}
+	}
+}
+
+func BenchmarkEach(b *testing.B) {
+	for _, dist := range benchDistributions {
+		sets, _ := filledSets(dist.n, dist.limit)
+		for i, set := range sets {
+			b.Run(dist.name+"/"+benchSets[i].name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					sum := uint32(0)
+					set.Each(func(v uint32) bool {
+						sum += v
+						return true
+					})
+				}
+				reportSize(b, set)
+			})
+		}
+	}
+}
+
+// BenchmarkInsert builds a whole set from random values per iteration, so
+// ns/op is the cost of n inserts
+func BenchmarkInsert(b *testing.B) {
+	for _, dist := range benchDistributions {
+		values := randomValues(rand.New(rand.NewSource(1)), dist.n/10, dist.limit)
+		for _, s := range benchSets {
+			b.Run(dist.name+"/"+s.name, func(b *testing.B) {
+				var set intSet
+				for i := 0; i < b.N; i++ {
+					set = s.make()
+					for _, v := range values {
+						set.Insert(v)
+					}
+				}
+				reportSize(b, set)
+			})
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"sort"
+	"unsafe"
+)
+
+// blockSize is how many values a PackedInts block holds before it splits
+const blockSize = 128
+
+// packedBlock holds sorted values: the first one in full and the rest as
+// varint gaps from the value before, so close values take a byte each
+type packedBlock struct {
+	first, last uint32
+	count       int
+	data        []byte
+}
+
+// encodeBlock packs sorted, distinct values into a block
+func encodeBlock(values []uint32) packedBlock {
+	b := packedBlock{first: values[0], last: values[len(values)-1], count: len(values)}
+	for i := 1; i < len(values); i++ {
+		b.data = binary.AppendUvarint(b.data, uint64(values[i]-values[i-1]))
+	}
+	return b
+}
+
+// each calls fn with the values of the block in order until it returns false
+func (b *packedBlock) each(fn func(uint32) bool) bool {
+	v := b.first
+	if !fn(v) {
+		return false
+	}
+	for i := 0; i < len(b.data); {
+		gap, n := binary.Uvarint(b.data[i:])
+		i += n
+		v += uint32(gap)
+		if !fn(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// decode unpacks the block into buf
+func (b *packedBlock) decode(buf []uint32) []uint32 {
+	buf = buf[:0]
+	b.each(func(v uint32) bool {
+		buf = append(buf, v)
+		return true
+	})
+	return buf
+}
+
+// PackedInts is a sorted set of uint32 values stored as delta + varint
+// encoded blocks. Dense data takes one or two bytes per value instead of
+// the eight of a []int; the price is decoding a block to look inside it.
+type PackedInts struct {
+	blocks  []packedBlock
+	n       int
+	scratch []uint32 // reused by Insert
+}
+
+// find returns the block v belongs in: the last block starting at or
+// before v, or the first block
+func (p *PackedInts) find(v uint32) int {
+	i := sort.Search(len(p.blocks), func(i int) bool { return p.blocks[i].first > v })
+	return max(i-1, 0)
+}
+
+// Insert adds v and reports whether it was new
+func (p *PackedInts) Insert(v uint32) bool {
+	if len(p.blocks) == 0 {
+		p.blocks = append(p.blocks, packedBlock{first: v, last: v, count: 1})
+		p.n++
+		return true
+	}
+	i := p.find(v)
+	b := &p.blocks[i]
+
+	// Values past the end of a block are appended without decoding it, so
+	// loading sorted data is fast and leaves every block full
+	if v > b.last {
+		if b.count == blockSize && i == len(p.blocks)-1 {
+			p.blocks = append(p.blocks, packedBlock{first: v, last: v, count: 1})
+			p.n++
+			return true
+		}
+		if b.count < blockSize {
+			b.data = binary.AppendUvarint(b.data, uint64(v-b.last))
+			b.last = v
+			b.count++
+			p.n++
+			return true
+		}
+	}
+
+	values := b.decode(p.scratch)
+	p.scratch = values
+	j, found := slices.BinarySearch(values, v)
+	if found {
+		return false
+	}
+	values = slices.Insert(values, j, v)
+	p.scratch = values
+	if len(values) <= blockSize {
+		p.blocks[i] = encodeBlock(values)
+	} else {
+		half := len(values) / 2
+		p.blocks = slices.Insert(p.blocks, i+1, encodeBlock(values[half:]))
+		p.blocks[i] = encodeBlock(values[:half])
+	}
+	p.n++
+	return true
+}
+
+// Contains reports whether v is in the set
+func (p *PackedInts) Contains(v uint32) bool {
+	if len(p.blocks) == 0 {
+		return false
+	}
+	b := &p.blocks[p.find(v)]
+	if v < b.first || v > b.last {
+		return false
+	}
+	found := false
+	b.each(func(x uint32) bool {
+		found = x == v
+		return x < v
+	})
+	return found
+}
+
+// Rank returns how many values are less than or equal to v
+func (p *PackedInts) Rank(v uint32) int {
+	if len(p.blocks) == 0 || v < p.blocks[0].first {
+		return 0
+	}
+	i := p.find(v)
+	rank := 0
+	for _, b := range p.blocks[:i] {
+		rank += b.count
+	}
+	p.blocks[i].each(func(x uint32) bool {
+		if x > v {
+			return false
+		}
+		rank++
+		return true
+	})
+	return rank
+}
+
+// Each calls fn with every value in order until fn returns false
+func (p *PackedInts) Each(fn func(uint32) bool) {
+	for i := range p.blocks {
+		if !p.blocks[i].each(fn) {
+			return
+		}
+	}
+}
+
+// Len returns the number of values
+func (p *PackedInts) Len() int {
+	return p.n
+}
+
+// SizeBytes estimates the memory the set uses
+func (p *PackedInts) SizeBytes() int {
+	size := cap(p.blocks) * int(unsafe.Sizeof(packedBlock{}))
+	for _, b := range p.blocks {
+		size += cap(b.data)
+	}
+	return size
+}
+
+// packedMagic starts the serialized form of a PackedInts
+const packedMagic = "PKI1"
+
+// MarshalBinary writes the blocks as they are: the magic, the number of
+// blocks, then for each its first value, count, data length and data, all
+// as uvarints
+func (p *PackedInts) MarshalBinary() ([]byte, error) {
+	out := []byte(packedMagic)
+	out = binary.AppendUvarint(out, uint64(len(p.blocks)))
+	for _, b := range p.blocks {
+		out = binary.AppendUvarint(out, uint64(b.first))
+		out = binary.AppendUvarint(out, uint64(b.count))
+		out = binary.AppendUvarint(out, uint64(len(b.data)))
+		out = append(out, b.data...)
+	}
+	return out, nil
+}
+
+// UnmarshalBinary reads what MarshalBinary wrote, checking that the values
+// really are sorted and distinct
+func (p *PackedInts) UnmarshalBinary(data []byte) error {
+	if len(data) < len(packedMagic) || string(data[:len(packedMagic)]) != packedMagic {
+		return fmt.Errorf("not a packed integer file")
+	}
+	data = data[len(packedMagic):]
+	next := func() (uint64, error) {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return 0, fmt.Errorf("packed integer file is truncated")
+		}
+		data = data[n:]
+		return v, nil
+	}
+
+	nblocks, err := next()
+	if err != nil {
+		return err
+	}
+	var blocks []packedBlock
+	total := 0
+	for range nblocks {
+		var fields [3]uint64
+		for i := range fields {
+			if fields[i], err = next(); err != nil {
+				return err
+			}
+		}
+		first, count, size := fields[0], fields[1], fields[2]
+		if first > 1<<32-1 || count == 0 || count > blockSize || size > uint64(len(data)) {
+			return fmt.Errorf("packed integer file has a bad block")
+		}
+		b := packedBlock{first: uint32(first), count: int(count), data: append([]byte(nil), data[:size]...)}
+		data = data[size:]
+
+		// Walk the gaps to find the last value and check the block
+		seen, last := 0, uint64(first)
+		for i := 0; i < len(b.data); seen++ {
+			gap, n := binary.Uvarint(b.data[i:])
+			if n <= 0 || gap == 0 || last+gap > 1<<32-1 {
+				return fmt.Errorf("packed integer file has a bad block")
+			}
+			i += n
+			last += gap
+		}
+		if seen+1 != b.count || len(blocks) > 0 && uint32(first) <= blocks[len(blocks)-1].last {
+			return fmt.Errorf("packed integer file has a bad block")
+		}
+		b.last = uint32(last)
+		blocks = append(blocks, b)
+		total += b.count
+	}
+	if len(data) != 0 {
+		return fmt.Errorf("packed integer file has extra data at the end")
+	}
+	p.blocks, p.n = blocks, total
+	return nil
+}
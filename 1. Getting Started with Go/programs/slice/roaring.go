@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"slices"
+	"sort"
+	"unsafe"
+)
+
+// arrayMax is the most values an array container holds; past it a bitmap
+// of 2^16 bits (8 KiB) is smaller than 2 bytes a value
+const arrayMax = 4096
+
+// bitmapWords is the size of a bitmap container in 64-bit words
+const bitmapWords = 1 << 16 / 64
+
+// container holds the values that share their upper 16 bits, as a sorted
+// array of the lower 16 bits while there are few and as a bitmap after that
+type container struct {
+	key    uint16
+	card   int
+	array  []uint16 // used while bitmap is nil
+	bitmap []uint64
+}
+
+// toBitmap switches a full array container to a bitmap
+func (c *container) toBitmap() {
+	c.bitmap = make([]uint64, bitmapWords)
+	for _, low := range c.array {
+		c.bitmap[low/64] |= 1 << (low % 64)
+	}
+	c.array = nil
+}
+
+func (c *container) contains(low uint16) bool {
+	if c.bitmap != nil {
+		return c.bitmap[low/64]&(1<<(low%64)) != 0
+	}
+	_, found := slices.BinarySearch(c.array, low)
+	return found
+}
+
+// rank counts the values in the container that are <= low
+func (c *container) rank(low uint16) int {
+	if c.bitmap == nil {
+		return sort.Search(len(c.array), func(i int) bool { return c.array[i] > low })
+	}
+	n := 0
+	for _, w := range c.bitmap[:low/64] {
+		n += bits.OnesCount64(w)
+	}
+	// Bits up to and including low; for bit 63 the shift gives 0 and the
+	// mask wraps to all ones
+	mask := uint64(1)<<(low%64+1) - 1
+	return n + bits.OnesCount64(c.bitmap[low/64]&mask)
+}
+
+// Bitmap is a sorted set of uint32 values in the style of a roaring bitmap:
+// values are split by their upper 16 bits into containers, each an array or
+// a bitmap depending on how full it is. Sparse and dense data both stay
+// small, and lookups never decode anything.
+type Bitmap struct {
+	containers []container // sorted by key
+	n          int
+}
+
+// find returns where the container for key is or would go
+func (b *Bitmap) find(key uint16) (int, bool) {
+	i := sort.Search(len(b.containers), func(i int) bool { return b.containers[i].key >= key })
+	return i, i < len(b.containers) && b.containers[i].key == key
+}
+
+// Insert adds v and reports whether it was new
+func (b *Bitmap) Insert(v uint32) bool {
+	key, low := uint16(v>>16), uint16(v)
+	i, ok := b.find(key)
+	if !ok {
+		b.containers = slices.Insert(b.containers, i, container{key: key})
+	}
+	c := &b.containers[i]
+
+	if c.bitmap != nil {
+		if c.contains(low) {
+			return false
+		}
+		c.bitmap[low/64] |= 1 << (low % 64)
+	} else {
+		j, found := slices.BinarySearch(c.array, low)
+		if found {
+			return false
+		}
+		c.array = slices.Insert(c.array, j, low)
+		if len(c.array) > arrayMax {
+			c.toBitmap()
+		}
+	}
+	c.card++
+	b.n++
+	return true
+}
+
+// Contains reports whether v is in the set
+func (b *Bitmap) Contains(v uint32) bool {
+	i, ok := b.find(uint16(v >> 16))
+	return ok && b.containers[i].contains(uint16(v))
+}
+
+// Rank returns how many values are less than or equal to v
+func (b *Bitmap) Rank(v uint32) int {
+	i, ok := b.find(uint16(v >> 16))
+	rank := 0
+	for _, c := range b.containers[:i] {
+		rank += c.card
+	}
+	if ok {
+		rank += b.containers[i].rank(uint16(v))
+	}
+	return rank
+}
+
+// Each calls fn with every value in order until fn returns false
+func (b *Bitmap) Each(fn func(uint32) bool) {
+	for _, c := range b.containers {
+		high := uint32(c.key) << 16
+		if c.bitmap == nil {
+			for _, low := range c.array {
+				if !fn(high | uint32(low)) {
+					return
+				}
+			}
+			continue
+		}
+		for w, word := range c.bitmap {
+			for word != 0 {
+				bit := bits.TrailingZeros64(word)
+				if !fn(high | uint32(w*64+bit)) {
+					return
+				}
+				word &= word - 1
+			}
+		}
+	}
+}
+
+// Len returns the number of values
+func (b *Bitmap) Len() int {
+	return b.n
+}
+
+// SizeBytes estimates the memory the set uses
+func (b *Bitmap) SizeBytes() int {
+	size := cap(b.containers) * int(unsafe.Sizeof(container{}))
+	for _, c := range b.containers {
+		size += cap(c.array)*2 + len(c.bitmap)*8
+	}
+	return size
+}
+
+// bitmapMagic starts the serialized form of a Bitmap
+const bitmapMagic = "RBM1"
+
+// MarshalBinary writes the magic and the number of containers, then for
+// each its key (2 bytes), kind (0 array, 1 bitmap) and cardinality, and
+// the array values (2 bytes each) or bitmap words (8 bytes each), little
+// endian
+func (b *Bitmap) MarshalBinary() ([]byte, error) {
+	out := []byte(bitmapMagic)
+	out = binary.AppendUvarint(out, uint64(len(b.containers)))
+	for _, c := range b.containers {
+		out = binary.LittleEndian.AppendUint16(out, c.key)
+		if c.bitmap == nil {
+			out = append(out, 0)
+			out = binary.AppendUvarint(out, uint64(c.card))
+			for _, low := range c.array {
+				out = binary.LittleEndian.AppendUint16(out, low)
+			}
+		} else {
+			out = append(out, 1)
+			out = binary.AppendUvarint(out, uint64(c.card))
+			for _, w := range c.bitmap {
+				out = binary.LittleEndian.AppendUint64(out, w)
+			}
+		}
+	}
+	return out, nil
+}
+
+// UnmarshalBinary reads what MarshalBinary wrote, checking keys are in
+// order, arrays are sorted and cardinalities add up
+func (b *Bitmap) UnmarshalBinary(data []byte) error {
+	bad := fmt.Errorf("bitmap file is damaged")
+	if len(data) < len(bitmapMagic) || string(data[:len(bitmapMagic)]) != bitmapMagic {
+		return fmt.Errorf("not a bitmap file")
+	}
+	data = data[len(bitmapMagic):]
+	count, n := binary.Uvarint(data)
+	if n <= 0 || count > 1<<16 {
+		return bad
+	}
+	data = data[n:]
+
+	var containers []container
+	total := 0
+	for range count {
+		if len(data) < 3 {
+			return bad
+		}
+		c := container{key: binary.LittleEndian.Uint16(data)}
+		kind := data[2]
+		card, n := binary.Uvarint(data[3:])
+		if n <= 0 || card == 0 || card > 1<<16 {
+			return bad
+		}
+		data = data[3+n:]
+		if len(containers) > 0 && c.key <= containers[len(containers)-1].key {
+			return bad
+		}
+		c.card = int(card)
+
+		switch kind {
+		case 0:
+			if card > arrayMax || len(data) < 2*int(card) {
+				return bad
+			}
+			c.array = make([]uint16, card)
+			for i := range c.array {
+				c.array[i] = binary.LittleEndian.Uint16(data[2*i:])
+				if i > 0 && c.array[i] <= c.array[i-1] {
+					return bad
+				}
+			}
+			data = data[2*card:]
+		case 1:
+			if len(data) < 8*bitmapWords {
+				return bad
+			}
+			c.bitmap = make([]uint64, bitmapWords)
+			ones := 0
+			for i := range c.bitmap {
+				c.bitmap[i] = binary.LittleEndian.Uint64(data[8*i:])
+				ones += bits.OnesCount64(c.bitmap[i])
+			}
+			if ones != c.card {
+				return bad
+			}
+			data = data[8*bitmapWords:]
+		default:
+			return bad
+		}
+		containers = append(containers, c)
+		total += c.card
+	}
+	if len(data) != 0 {
+		return bad
+	}
+	b.containers, b.n = containers, total
+	return nil
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"programs/intervals"
+)
+
+// The program imports the intervals package from the directory next to this
+// one, so run it from here with "go run ." rather than "go run slice.go"
+func main() {
+	trace := flag.Bool("trace", false, "show len, cap and reallocations on every append, and allow sub-slices")
+	modeFlag := flag.String("mode", "list", "list keeps duplicates, set refuses them, multiset counts them")
+	ranges := flag.Bool("ranges", false, "show runs of consecutive numbers as ranges like 1-5, 8, 10-12")
+	flag.Parse()
+
+	mode := Mode(*modeFlag)
+	if mode != ModeList && mode != ModeSet && mode != ModeMultiset {
+		fmt.Println("Invalid mode. Please use list, set or multiset.")
+		os.Exit(1)
+	}
+
+	nums := make([]int, 0, 3)
+	tracer := &sliceTracer{w: os.Stdout, allocated: cap(nums) * intSize}
+	push := func(s []int, v int) []int { return append(s, v) }
+	if *trace {
+		fmt.Println("Trace mode: also 'sub i j' takes nums[i:j], 'append s1 n' appends to a")
+		fmt.Println("sub-slice and 'show' draws the backing arrays. Allocated bytes are")
+		fmt.Println("estimates: the runtime rounds each allocation up to a size class.")
+		push = func(s []int, v int) []int { return tracer.append("nums", s, v) }
+	}
+	fmt.Println("Commands: freq, remove n, removeall n, save file, load file, union, intersect, diff.")
+	fmt.Println(`Range commands: add "1-5, 8", del "3-4", gaps [lo-hi], coverage.`)
+	var other []int // the second set, from "load"
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Print("Enter an integer (or 'X' to quit): ")
+		line, err := reader.ReadString('\n')
+		input := strings.TrimSpace(line)
+		if input == "X" || input == "x" || input == "" && err != nil {
+			fmt.Println("Exiting program.")
+			break
+		}
+
+		if *trace {
+			if handled := traceCommand(tracer, nums, strings.Fields(input)); handled {
+				continue
+			}
+		}
+
+		if handled := collectionCommand(mode, *ranges, &nums, &other, strings.Fields(input)); handled {
+			continue
+		}
+
+		if handled := rangeCommand(mode, *ranges, &nums, input, push); handled {
+			continue
+		}
+
+		num, err := strconv.Atoi(input)
+		if err != nil {
+			fmt.Println("Invalid input. Please enter a number or 'X'.")
+			continue
+		}
+		if mode == ModeSet && contains(nums, num) {
+			fmt.Println(num, "is already in the set.")
+			continue
+		}
+
+		nums = push(nums, num)
+		sort.Ints(nums)
+		printCollection(nums, mode, *ranges)
+	}
+}
+
+// printCollection prints nums after every change, as runs when ranges is
+// set
+func printCollection(nums []int, mode Mode, ranges bool) {
+	if ranges {
+		set := intervals.FromInts(nums)
+		fmt.Print("Ranges: ", set)
+		if repeats := len(nums) - int(set.Len()); repeats > 0 {
+			fmt.Printf(" (plus %d repeated value(s))", repeats)
+		}
+		fmt.Println()
+		return
+	}
+	switch mode {
+	case ModeSet:
+		fmt.Println("Set:", formatCollection(nums, mode))
+	case ModeMultiset:
+		fmt.Println("Multiset:", formatCollection(nums, mode))
+	default:
+		fmt.Println("Sorted slice:", nums)
+	}
+}
+
+// collectionCommand runs the frequency, remove, save, load and set operation
+// commands and reports whether input was one of them. other is the second
+// set that union, intersect and diff combine nums with.
+func collectionCommand(mode Mode, ranges bool, nums, other *[]int, words []string) bool {
+	if len(words) == 0 {
+		return false
+	}
+	switch words[0] {
+	case "freq":
+		printFrequencies(os.Stdout, *nums)
+	case "remove", "removeall":
+		if len(words) != 2 {
+			fmt.Printf("Usage: %s n\n", words[0])
+			return true
+		}
+		n, err := strconv.Atoi(words[1])
+		if err != nil {
+			fmt.Println("Invalid number:", words[1])
+			return true
+		}
+		removed := 0
+		if words[0] == "remove" {
+			var ok bool
+			if *nums, ok = removeOne(*nums, n); ok {
+				removed = 1
+			}
+		} else {
+			*nums, removed = removeAll(*nums, n)
+		}
+		if removed == 0 {
+			fmt.Println(n, "is not there.")
+			return true
+		}
+		fmt.Printf("Removed %d occurrence(s) of %d.\n", removed, n)
+		printCollection(*nums, mode, ranges)
+	case "load":
+		if len(words) != 2 {
+			fmt.Println("Usage: load file")
+			return true
+		}
+		loaded, err := loadInts(words[1], mode)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return true
+		}
+		*other = loaded
+		fmt.Println("Second set:", formatCollection(*other, mode))
+	case "save":
+		if len(words) != 2 {
+			fmt.Println("Usage: save file")
+			return true
+		}
+		kind, err := saveInts(words[1], *nums)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return true
+		}
+		fmt.Printf("Saved %d value(s) to %s as a %s set.\n", len(*nums), words[1], kind)
+	case "union", "intersect", "diff":
+		if *other == nil {
+			fmt.Println("Load a second set first with 'load file'.")
+			return true
+		}
+		ops := map[string]func(a, b []int) []int{"union": union, "intersect": intersection, "diff": difference}
+		fmt.Printf("%s: %s\n", words[0], formatCollection(ops[words[0]](*nums, *other), mode))
+	default:
+		return false
+	}
+	return true
+}
+
+// maxRangeAdd limits how many numbers one "add" may put into nums
+const maxRangeAdd = 1 << 20
+
+// rangeCommand runs the add, del, gaps and coverage commands, which take
+// range expressions like "1-5, 8", and reports whether input was one of them.
+// push appends one number to nums, through the tracer in trace mode.
+func rangeCommand(mode Mode, ranges bool, nums *[]int, input string, push func([]int, int) []int) bool {
+	name, expr, _ := strings.Cut(input, " ")
+	switch name {
+	case "add", "del":
+		set, err := intervals.ParseIntervals(expr)
+		if err != nil {
+			fmt.Println("Invalid range:", err)
+			return true
+		}
+		if set.Len() == 0 {
+			fmt.Printf("Usage: %s 1-5, 8\n", name)
+			return true
+		}
+		if name == "del" {
+			kept := (*nums)[:0]
+			for _, n := range *nums {
+				if !set.Contains(n) {
+					kept = append(kept, n)
+				}
+			}
+			fmt.Printf("Removed %d value(s).\n", len(*nums)-len(kept))
+			*nums = kept
+			printCollection(*nums, mode, ranges)
+			return true
+		}
+		if set.Len() > maxRangeAdd {
+			fmt.Printf("That is %d numbers; add at most %d at a time.\n", set.Len(), maxRangeAdd)
+			return true
+		}
+		var added int
+		*nums, added = addRange(mode, *nums, set, push)
+		fmt.Printf("Added %d value(s).\n", added)
+		printCollection(*nums, mode, ranges)
+	case "gaps":
+		set := intervals.FromInts(*nums)
+		gaps := set.Gaps()
+		if expr != "" {
+			window, err := intervals.ParseInterval(strings.TrimSpace(expr))
+			if err != nil {
+				fmt.Println("Invalid range:", err)
+				return true
+			}
+			gaps = set.GapsIn(window.Lo, window.Hi)
+		}
+		if len(gaps) == 0 {
+			fmt.Println("No gaps.")
+			return true
+		}
+		fmt.Println("Gaps:", intervals.FromIntervals(gaps))
+	case "coverage":
+		c := intervals.FromInts(*nums).Coverage()
+		if c.Values == 0 {
+			fmt.Println("  (empty)")
+			return true
+		}
+		fmt.Printf("  %d distinct value(s) in %d run(s), span %s\n", c.Values, c.Runs, c.Span)
+		fmt.Printf("  %.1f%% of the span covered, %d missing in %d gap(s)\n", c.Percent(), c.Missing, c.Gaps)
+		fmt.Printf("  longest run %s (%d)", c.LongestRun, c.LongestRun.Len())
+		if c.Gaps > 0 {
+			fmt.Printf(", longest gap %s (%d)", c.LongestGap, c.LongestGap.Len())
+		}
+		fmt.Println()
+	default:
+		return false
+	}
+	return true
+}
+
+// addRange adds every number in set to the sorted slice nums and returns
+// the result, still sorted, with how many numbers went in. In set mode
+// numbers nums already holds are left out. Each number is appended with
+// push.
+func addRange(mode Mode, nums []int, set *intervals.IntervalSet, push func([]int, int) []int) ([]int, int) {
+	have := intervals.FromInts(nums)
+	added := 0
+	set.Each(func(n int) bool {
+		if mode != ModeSet || !have.Contains(n) {
+			nums = push(nums, n)
+			added++
+		}
+		return true
+	})
+	sort.Ints(nums)
+	return nums, added
+}
+
+// traceCommand runs the sub, append and show commands of trace mode and
+// reports whether input was one of them
+func traceCommand(t *sliceTracer, nums []int, words []string) bool {
+	if len(words) == 0 {
+		return false
+	}
+	switch words[0] {
+	case "sub":
+		if len(words) != 3 {
+			fmt.Println("Usage: sub i j")
+			return true
+		}
+		i, err1 := strconv.Atoi(words[1])
+		j, err2 := strconv.Atoi(words[2])
+		if err1 != nil || err2 != nil {
+			fmt.Println("Usage: sub i j")
+			return true
+		}
+		name, err := t.sub(nums, i, j)
+		if err != nil {
+			fmt.Println("Invalid sub-slice:", err)
+			return true
+		}
+		fmt.Printf("%s = nums[%d:%d]\n", name, i, j)
+		t.draw(nums)
+	case "append":
+		if len(words) != 3 {
+			fmt.Println("Usage: append s1 n")
+			return true
+		}
+		k, err := t.find(words[1])
+		if err != nil {
+			fmt.Println(err)
+			return true
+		}
+		n, err := strconv.Atoi(words[2])
+		if err != nil {
+			fmt.Println("Invalid number:", words[2])
+			return true
+		}
+		t.warnOverwrite(nums, k)
+		t.views[k].s = t.append(words[1], t.views[k].s, n)
+		t.draw(nums)
+	case "show":
+		t.draw(nums)
+	default:
+		return false
+	}
+	return true
+}
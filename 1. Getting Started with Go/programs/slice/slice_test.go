@@ -0,0 +1,70 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"programs/intervals"
+)
+
+func TestAddRange(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  Mode
+		nums  []int
+		expr  string
+		want  []int
+		added int
+	}{
+		{"set skips values already there", ModeSet, []int{3}, "1-5", []int{1, 2, 3, 4, 5}, 4},
+		{"set with several present", ModeSet, []int{2, 4, 9}, "1-5, 9", []int{1, 2, 3, 4, 5, 9}, 3},
+		{"list keeps duplicates", ModeList, []int{3}, "1-5", []int{1, 2, 3, 3, 4, 5}, 5},
+		{"multiset keeps duplicates", ModeMultiset, []int{3, 3}, "3-4", []int{3, 3, 3, 4}, 2},
+		{"into empty set", ModeSet, nil, "-2-0, 7", []int{-2, -1, 0, 7}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := intervals.ParseIntervals(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			push := func(s []int, v int) []int { return append(s, v) }
+			got, added := addRange(tt.mode, slices.Clone(tt.nums), set, push)
+			if !slices.Equal(got, tt.want) || added != tt.added {
+				t.Errorf("got %v with %d added, want %v with %d", got, added, tt.want, tt.added)
+			}
+		})
+	}
+}
+
+func TestAddRangeIsTraced(t *testing.T) {
+	var out strings.Builder
+	tracer := &sliceTracer{w: &out}
+	push := func(s []int, v int) []int { return tracer.append("nums", s, v) }
+	set, _ := intervals.ParseIntervals("1-5")
+	nums, _ := addRange(ModeList, make([]int, 0, 3), set, push)
+	if len(nums) != 5 {
+		t.Fatalf("got %v", nums)
+	}
+	trace := out.String()
+	if n := strings.Count(trace, "append "); n != 5 {
+		t.Errorf("%d appends traced, want 5:\n%s", n, trace)
+	}
+	if !strings.Contains(trace, "reallocated") || !strings.Contains(trace, "bytes allocated") {
+		t.Errorf("growing past cap 3 is not reported:\n%s", trace)
+	}
+}
+
+func TestRangeAddRefusesHugeRanges(t *testing.T) {
+	for _, expr := range []string{"0-9223372036854775807", "-9223372036854775808-9223372036854775807", "1-2000000"} {
+		nums := []int{}
+		push := func(s []int, v int) []int { return append(s, v) }
+		if !rangeCommand(ModeList, false, &nums, "add "+expr, push) {
+			t.Fatalf("add %s: not handled", expr)
+		}
+		if len(nums) != 0 {
+			t.Errorf("add %s: added %d values", expr, len(nums))
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unsafe"
+)
+
+// intSize is the size of one element of a []int, in bytes
+const intSize = int(unsafe.Sizeof(int(0)))
+
+// view is a named slice the tracer draws, such as nums or a sub-slice of it
+type view struct {
+	name string
+	s    []int
+}
+
+// sliceTracer reports what append does to len, cap and the backing array,
+// and draws which slices share a backing array
+type sliceTracer struct {
+	w         io.Writer
+	views     []view // sub-slices taken with "sub", in order
+	allocated int    // estimated bytes allocated for backing arrays so far
+}
+
+// dataAddr is the address of the first element of s's backing array that s
+// can see. Two slices share an array when their capacity ends at the same
+// address.
+func dataAddr(s []int) uintptr {
+	return uintptr(unsafe.Pointer(unsafe.SliceData(s)))
+}
+
+func capEnd(s []int) uintptr {
+	return dataAddr(s) + uintptr(cap(s)*intSize)
+}
+
+// append appends v to s like the builtin and reports what changed. The
+// bytes allocated are estimated as cap × the int size; the runtime rounds
+// each allocation up to one of its size classes, so it may take a little
+// more.
+func (t *sliceTracer) append(name string, s []int, v int) []int {
+	oldLen, oldCap, oldAddr := len(s), cap(s), dataAddr(s)
+	s = append(s, v)
+
+	fmt.Fprintf(t.w, "  append %d to %s: len %d -> %d, cap %d -> %d", v, name, oldLen, len(s), oldCap, cap(s))
+	if dataAddr(s) != oldAddr {
+		bytes := cap(s) * intSize
+		t.allocated += bytes
+		fmt.Fprintf(t.w, "\n  reallocated: %#x -> %#x, copied %d elements, about %d bytes allocated (~%d in all)\n",
+			oldAddr, dataAddr(s), oldLen, bytes, t.allocated)
+	} else {
+		fmt.Fprintf(t.w, ", same backing array %#x\n", dataAddr(s))
+	}
+	return s
+}
+
+// sub records s[i:j] under a new name and returns the name
+func (t *sliceTracer) sub(s []int, i, j int) (string, error) {
+	if i < 0 || j < i || j > cap(s) {
+		return "", fmt.Errorf("need 0 <= i <= j <= cap %d", cap(s))
+	}
+	name := fmt.Sprintf("s%d", len(t.views)+1)
+	t.views = append(t.views, view{name, s[i:j]})
+	return name, nil
+}
+
+// find returns the index of the sub-slice with the given name
+func (t *sliceTracer) find(name string) (int, error) {
+	for i, v := range t.views {
+		if v.name == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no sub-slice named %q", name)
+}
+
+// warnOverwrite tells when appending to sub-slice k will write over an
+// element that nums or another sub-slice holds, since they share the array
+func (t *sliceTracer) warnOverwrite(nums []int, k int) {
+	s := t.views[k].s
+	if len(s) == cap(s) {
+		return // append will reallocate instead
+	}
+	slot := dataAddr(s) + uintptr(len(s)*intSize)
+	for i, v := range append([]view{{"nums", nums}}, t.views...) {
+		if i-1 == k || len(v.s) == 0 {
+			continue
+		}
+		if slot >= dataAddr(v.s) && slot < dataAddr(v.s)+uintptr(len(v.s)*intSize) {
+			index := int(slot-dataAddr(v.s)) / intSize
+			fmt.Fprintf(t.w, "  note: this overwrites %s[%d], which was %d\n", v.name, index, v.s[index])
+		}
+	}
+}
+
+// draw prints every backing array that nums or a sub-slice uses, with a bar
+// per slice: "=" for the elements within its len, "-" for spare capacity
+func (t *sliceTracer) draw(nums []int) {
+	all := append([]view{{"nums", nums}}, t.views...)
+
+	// Group the slices by array, in the order they are listed
+	var ends []uintptr
+	groups := make(map[uintptr][]view)
+	for _, v := range all {
+		if cap(v.s) == 0 {
+			fmt.Fprintf(t.w, "  %s has no backing array (cap 0)\n", v.name)
+			continue
+		}
+		end := capEnd(v.s)
+		if _, ok := groups[end]; !ok {
+			ends = append(ends, end)
+		}
+		groups[end] = append(groups[end], v)
+	}
+
+	for _, end := range ends {
+		views := groups[end]
+		// The array starts at the lowest address any slice in it can see
+		start := dataAddr(views[0].s)
+		for _, v := range views {
+			start = min(start, dataAddr(v.s))
+		}
+		n := int(end-start) / intSize
+
+		values := make([]int, n)
+		for _, v := range views {
+			full := v.s[:cap(v.s)]
+			offset := int(dataAddr(v.s)-start) / intSize
+			copy(values[offset:], full)
+		}
+		width := 4
+		for _, x := range values {
+			width = max(width, len(fmt.Sprint(x))+1)
+		}
+		nameWidth := 6
+		for _, v := range views {
+			nameWidth = max(nameWidth, len(v.name)+2)
+		}
+
+		fmt.Fprintf(t.w, "  backing array from %#x: %d ints, %d bytes\n", start, n, n*intSize)
+		fmt.Fprintf(t.w, "  %-*s", nameWidth, "index")
+		for i := range n {
+			fmt.Fprintf(t.w, "%*d", width, i)
+		}
+		fmt.Fprintf(t.w, "\n  %-*s", nameWidth, "value")
+		for _, x := range values {
+			fmt.Fprintf(t.w, "%*d", width, x)
+		}
+		fmt.Fprintln(t.w)
+		for _, v := range views {
+			offset := int(dataAddr(v.s)-start) / intSize
+			bar := strings.Repeat(" ", offset*width) +
+				strings.Repeat("=", len(v.s)*width) +
+				strings.Repeat("-", (cap(v.s)-len(v.s))*width)
+			fmt.Fprintf(t.w, "  %-*s%s  len %d, cap %d\n", nameWidth, v.name, bar, len(v.s), cap(v.s))
+		}
+	}
+}